@@ -0,0 +1,180 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// SessionsHandler lists the devices the user is currently signed in on.
+func (app *Application) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	sessions, err := app.SessionModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// Work out which of the rows belongs to this browser
+	var currentID int64
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if session.ID != "" {
+		current, err := app.SessionModel.GetByToken(session.ID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.ServerError(w, err)
+			return
+		}
+		if current != nil {
+			currentID = current.ID
+		}
+	}
+
+	app.Render(w, r, "sessions.tmpl", map[string]interface{}{
+		"Sessions":  sessions,
+		"CurrentID": currentID,
+	})
+}
+
+// RevokeSessionHandler signs the user out of a single device.
+func (app *Application) RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.ParseInt(r.PostForm.Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.SessionModel.Delete(id, user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditSessionRevoke, TargetType: "session", TargetID: id})
+
+	app.flashRedirect(w, r, "Session revoked.", "/account/sessions")
+}
+
+// RevokeOtherSessionsHandler signs the user out everywhere except the current browser.
+func (app *Application) RevokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.SessionModel.DeleteAllForUser(user.ID, session.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{
+		Action:     data.AuditSessionRevoke,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"all_other_sessions": true},
+	})
+
+	app.flashRedirect(w, r, "Signed out of all other sessions.", "/account/sessions")
+}
+
+// ChangePasswordForm displays the form to change the current password.
+func (app *Application) ChangePasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.Render(w, r, "change_password.tmpl", nil)
+}
+
+// ChangePasswordHandler updates the user's password and revokes their other sessions.
+func (app *Application) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	currentPassword := r.PostForm.Get("current_password")
+	password := r.PostForm.Get("password")
+	confirmation := r.PostForm.Get("password_confirmation")
+
+	v := NewValidator()
+	v.Check(user.MatchesPassword(currentPassword) == nil, "current_password", "Current password is incorrect")
+	if err := app.PasswordPolicy.Validate(v, password, user.Email); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	v.Check(password == confirmation, "password_confirmation", "Passwords do not match")
+
+	if !v.Valid() {
+		app.Render(w, r, "change_password.tmpl", map[string]interface{}{
+			"Errors": v.Errors,
+		})
+		return
+	}
+
+	err = user.SetPassword(password)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.UserModel.UpdatePassword(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// A password change ends every other session, in case the old password leaked
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.SessionModel.DeleteAllForUser(user.ID, session.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditPasswordChange, TargetType: "user", TargetID: user.ID})
+
+	app.flashRedirect(w, r, "Password changed. You have been signed out of all other sessions.", "/account/sessions")
+}
+
+// flashRedirect stores a flash message in the session and redirects to target.
+func (app *Application) flashRedirect(w http.ResponseWriter, r *http.Request, message, target string) {
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	session.AddFlash(message)
+	if err := session.Save(r, w); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
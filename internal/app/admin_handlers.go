@@ -0,0 +1,407 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+const (
+	adminPageSize = 20
+
+	// forcedResetTTL is how long the link sent by an admin-forced password reset stays valid
+	forcedResetTTL = 24 * time.Hour
+)
+
+// AdminHandler shows the admin dashboard with headline counts.
+func (app *Application) AdminHandler(w http.ResponseWriter, r *http.Request) {
+	// Only the totals are needed, so fetch the smallest possible page
+	count := data.Filters{Page: 1, PageSize: 1}
+
+	_, userTotals, err := app.UserModel.List(count)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	_, storyTotals, err := app.StoryModel.List(count, data.StoryStateActive)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	_, deletedTotals, err := app.StoryModel.List(count, data.StoryStateDeleted)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "admin.tmpl", map[string]interface{}{
+		"UserCount":         userTotals.TotalRecords,
+		"StoryCount":        storyTotals.TotalRecords,
+		"DeletedStoryCount": deletedTotals.TotalRecords,
+	})
+}
+
+// AdminUsersHandler lists users with search, sorting and pagination.
+func (app *Application) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	filters := readFilters(r, "-created_at")
+
+	users, metadata, err := app.UserModel.List(filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "admin_users.tmpl", map[string]interface{}{
+		"Users":    users,
+		"Filters":  filters,
+		"Metadata": metadata,
+		"Roles":    data.Roles,
+	})
+}
+
+// AdminSetRoleHandler changes a user's role.
+func (app *Application) AdminSetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	role := r.PostForm.Get("role")
+	if !data.ValidRole(role) {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	before := userSnapshot(user)
+	err = app.UserModel.SetRole(user, data.Role(role))
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.auditAdminUser(r, data.AuditAdminSetRole, user, before)
+
+	app.flashRedirect(w, r, user.Email+" is now a "+role+".", adminReturnTo(r, "/admin/users"))
+}
+
+// AdminDisableUserHandler disables an account and signs it out everywhere.
+func (app *Application) AdminDisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	before := userSnapshot(user)
+	err := app.UserModel.SetDisabled(user, true)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.auditAdminUser(r, data.AuditAdminDisableUser, user, before)
+
+	err = app.SessionModel.DeleteAllForUser(user.ID, "")
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.flashRedirect(w, r, user.Email+" has been disabled.", adminReturnTo(r, "/admin/users"))
+}
+
+// AdminEnableUserHandler re-enables a disabled account.
+func (app *Application) AdminEnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	before := userSnapshot(user)
+	err := app.UserModel.SetDisabled(user, false)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.auditAdminUser(r, data.AuditAdminEnableUser, user, before)
+
+	app.flashRedirect(w, r, user.Email+" has been re-enabled.", adminReturnTo(r, "/admin/users"))
+}
+
+// AdminForcePasswordResetHandler blocks logins with the current password, signs
+// the user out everywhere and emails them a link to choose a new password.
+func (app *Application) AdminForcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	before := userSnapshot(user)
+	err := app.UserModel.RequirePasswordReset(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.auditAdminUser(r, data.AuditAdminForceReset, user, before)
+
+	err = app.SessionModel.DeleteAllForUser(user.ID, "")
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	token, err := app.OneTimeTokenModel.New(user.ID, data.PurposePasswordReset, forcedResetTTL)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.SendEmail(user.Email, "password_reset_required.tmpl", map[string]interface{}{
+		"URL": app.BaseURL + "/password/reset?token=" + url.QueryEscape(token.Plaintext),
+		"TTL": "24 hours",
+	})
+
+	app.flashRedirect(w, r, user.Email+" must now reset their password. A reset link has been emailed to them.", adminReturnTo(r, "/admin/users"))
+}
+
+// AdminStoriesHandler lists stories, including deleted ones, with search, sorting and pagination.
+func (app *Application) AdminStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	filters := readFilters(r, "-created_at")
+
+	state := r.URL.Query().Get("state")
+	if state != data.StoryStateDeleted && state != data.StoryStateAll {
+		state = data.StoryStateActive
+	}
+
+	stories, metadata, err := app.StoryModel.List(filters, state)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "admin_stories.tmpl", map[string]interface{}{
+		"Stories":  stories,
+		"Filters":  filters,
+		"Metadata": metadata,
+		"State":    state,
+	})
+}
+
+// AdminSoftDeleteStoryHandler hides a story while keeping it restorable.
+func (app *Application) AdminSoftDeleteStoryHandler(w http.ResponseWriter, r *http.Request) {
+	app.adminStoryAction(w, r, app.StoryModel.Get, app.StoryModel.SoftDelete, data.AuditAdminDeleteStory, "Story deleted. It can be restored from the deleted stories list.")
+}
+
+// AdminRestoreStoryHandler brings back a soft-deleted story.
+func (app *Application) AdminRestoreStoryHandler(w http.ResponseWriter, r *http.Request) {
+	app.adminStoryAction(w, r, app.StoryModel.GetDeleted, app.StoryModel.Restore, data.AuditAdminRestoreStory, "Story restored.")
+}
+
+// AdminPurgeStoryHandler permanently deletes a story. Only deleted stories can be
+// purged, so a live story always passes through the trash first.
+func (app *Application) AdminPurgeStoryHandler(w http.ResponseWriter, r *http.Request) {
+	app.adminStoryAction(w, r, app.StoryModel.GetDeleted, app.StoryModel.Purge, data.AuditAdminPurgeStory, "Story permanently deleted.")
+}
+
+// adminStoryAction loads the story named by the posted id field with load,
+// applies action to it, records it in the audit log as auditAction with a
+// snapshot of the story beforehand, and redirects back to the list.
+func (app *Application) adminStoryAction(w http.ResponseWriter, r *http.Request, load func(id int) (*data.Story, error), action func(id int) error, auditAction, message string) {
+	id, err := readIDForm(r)
+	if err != nil {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	story, err := load(id)
+	if err == nil {
+		err = action(story.ID)
+	}
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, auditEntry{Action: auditAction, TargetType: "story", TargetID: story.ID, Before: storySnapshot(story)})
+
+	app.flashRedirect(w, r, message, adminReturnTo(r, "/admin/stories"))
+}
+
+// auditAdminUser records an admin change to user, given a snapshot taken before it.
+func (app *Application) auditAdminUser(r *http.Request, action string, user *data.User, before map[string]interface{}) {
+	app.audit(r, auditEntry{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     before,
+		After:      userSnapshot(user),
+		Metadata:   map[string]interface{}{"email": user.Email},
+	})
+}
+
+// adminTargetUser loads the user named by the posted id field for an admin
+// action. Admins cannot act on their own account, so they cannot lock
+// themselves out. It writes the error response and returns false on failure.
+func (app *Application) adminTargetUser(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := readIDForm(r)
+	if err != nil {
+		app.ClientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	if id == app.ContextGetUser(r).ID {
+		app.flashRedirect(w, r, "You cannot change your own account from the admin area.", adminReturnTo(r, "/admin/users"))
+		return nil, false
+	}
+
+	user, err := app.UserModel.GetByID(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// readFilters reads the q, sort and page query parameters of an admin listing.
+func readFilters(r *http.Request, defaultSort string) data.Filters {
+	qs := r.URL.Query()
+
+	page, err := strconv.Atoi(qs.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	sort := qs.Get("sort")
+	if sort == "" {
+		sort = defaultSort
+	}
+
+	return data.Filters{
+		Search:   strings.TrimSpace(qs.Get("q")),
+		Sort:     sort,
+		Page:     page,
+		PageSize: adminPageSize,
+	}
+}
+
+// adminReturnTo sends the admin back to the listing they came from, keeping
+// its search and page, as long as the Referer is an admin page on this site.
+func adminReturnTo(r *http.Request, fallback string) string {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host != r.Host || !strings.HasPrefix(referer.Path, "/admin/") {
+		return fallback
+	}
+	return referer.RequestURI()
+}
+
+// auditExportLimit caps the number of events in one JSON export.
+const auditExportLimit = 10000
+
+// AdminAuditHandler shows the audit log, filtered by action, actor, target and date.
+func (app *Application) AdminAuditHandler(w http.ResponseWriter, r *http.Request) {
+	filter, query := readAuditFilter(r)
+	filters := readFilters(r, "")
+
+	events, metadata, err := app.AuditModel.List(filter, filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// Links keep the current filters; they are built here so the template does not re-encode them
+	pageURL := func(page int) string {
+		query.Set("page", strconv.Itoa(page))
+		return "/admin/audit?" + query.Encode()
+	}
+	query.Del("page")
+	exportURL := "/admin/audit/export?" + query.Encode()
+
+	app.Render(w, r, "admin_audit.tmpl", map[string]interface{}{
+		"Events":      events,
+		"Filter":      filter,
+		"From":        query.Get("from"),
+		"To":          query.Get("to"),
+		"Metadata":    metadata,
+		"Actions":     data.AuditActions,
+		"TargetTypes": data.AuditTargetTypes,
+		"PrevURL":     pageURL(metadata.CurrentPage - 1),
+		"NextURL":     pageURL(metadata.CurrentPage + 1),
+		"ExportURL":   exportURL,
+	})
+}
+
+// AdminAuditExportHandler downloads the filtered audit log as JSON.
+func (app *Application) AdminAuditExportHandler(w http.ResponseWriter, r *http.Request) {
+	filter, _ := readAuditFilter(r)
+
+	events, metadata, err := app.AuditModel.List(filter, data.Filters{Page: 1, PageSize: auditExportLimit})
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if events == nil {
+		events = []*data.AuditEvent{}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", `attachment; filename="audit-`+time.Now().UTC().Format("20060102-150405")+`.json"`)
+
+	err = app.WriteJSON(w, http.StatusOK, Envelope{"events": events, "metadata": metadata}, headers)
+	if err != nil {
+		app.ServerError(w, err)
+	}
+}
+
+// readAuditFilter reads the audit log filters from the query string. Dates are
+// whole days in UTC; "to" includes the whole of its day. Unparseable dates are
+// ignored. It also returns the recognised parameters, for building links.
+func readAuditFilter(r *http.Request) (data.AuditFilter, url.Values) {
+	qs := r.URL.Query()
+
+	filter := data.AuditFilter{
+		Action:     qs.Get("action"),
+		Actor:      strings.TrimSpace(qs.Get("actor")),
+		TargetType: qs.Get("target_type"),
+		TargetID:   strings.TrimSpace(qs.Get("target_id")),
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"action":      filter.Action,
+		"actor":       filter.Actor,
+		"target_type": filter.TargetType,
+		"target_id":   filter.TargetID,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	if from, err := time.Parse("2006-01-02", qs.Get("from")); err == nil {
+		filter.From = &from
+		query.Set("from", qs.Get("from"))
+	}
+	if to, err := time.Parse("2006-01-02", qs.Get("to")); err == nil {
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+		query.Set("to", qs.Get("to"))
+	}
+
+	return filter, query
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// storyInput is the JSON body accepted when creating or updating a story.
+type storyInput struct {
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Status      string     `json:"status"`       // defaults to published on create and to the current status on update
+	ScheduledAt *time.Time `json:"scheduled_at"` // required when status is scheduled; on update defaults to the current time
+	Tags        []string   `json:"tags"`         // normalised before saving; on update omit to keep the current tags
+	Version     *int       `json:"version"`      // on update, the version the edit is based on; omit to overwrite
+}
+
+// readIDParam parses the {id} path value of the current request.
+func readIDParam(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+	return id, nil
+}
+
+// readIDForm parses the id field of the current request's POST form. Actions
+// that change data take their target from the form, never the query string.
+func readIDForm(r *http.Request) (int, error) {
+	if err := r.ParseForm(); err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+	return id, nil
+}
+
+// readAPIFilters reads the page and page_size query parameters of JSON listings,
+// defaulting to the first page of 10 and allowing at most 100 per page.
+func readAPIFilters(r *http.Request) data.Filters {
+	qs := r.URL.Query()
+
+	page, err := strconv.Atoi(qs.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(qs.Get("page_size"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	return data.Filters{Page: page, PageSize: pageSize}
+}
+
+// APIListStoriesHandler returns a page of published stories as JSON. Clients
+// follow metadata.next_cursor and metadata.prev_cursor with the after and before
+// query parameters.
+func (app *Application) APIListStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	filters := readCursorFilters(r, readAPIFilters(r).PageSize)
+
+	stories, metadata, err := app.StoryModel.ListPublished(filters)
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidCursor) {
+			app.APIBadRequest(w, err)
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+	if stories == nil {
+		stories = []*data.Story{}
+	}
+
+	err = app.WriteJSON(w, http.StatusOK, Envelope{"stories": stories, "metadata": metadata}, nil)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
+
+// APIGetStoryHandler returns a single story as JSON.
+func (app *Application) APIGetStoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		app.APIClientError(w, http.StatusNotFound)
+		return
+	}
+
+	story, err := app.StoryModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.APIClientError(w, http.StatusNotFound)
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+
+	// Unshared and hidden stories are treated as missing for everyone who may not see them
+	if !Can(app.ContextGetUser(r), ActionViewStory, story) {
+		app.APIClientError(w, http.StatusNotFound)
+		return
+	}
+
+	err = app.WriteJSON(w, http.StatusOK, Envelope{"story": story}, nil)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
+
+// APICreateStoryHandler creates a story owned by the authenticated user.
+func (app *Application) APICreateStoryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	var input storyInput
+	err := app.ReadJSON(w, r, &input)
+	if err != nil {
+		app.APIBadRequest(w, err)
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = data.StatusPublished
+	}
+
+	tags := data.NormalizeTags(input.Tags)
+
+	v := NewValidator()
+	ValidateStory(v, input.Title, input.Content, input.Status)
+	ValidateSchedule(v, input.Status, input.ScheduledAt, app.Clock.Now())
+	ValidateTags(v, tags)
+	if !v.Valid() {
+		app.APIFailedValidation(w, v.Errors)
+		return
+	}
+
+	story := &data.Story{
+		Title:       input.Title,
+		Content:     input.Content,
+		UserID:      user.ID,
+		Status:      input.Status,
+		ScheduledAt: input.ScheduledAt,
+		Tags:        tags,
+		UserEmail:   user.Email,
+	}
+
+	err = app.StoryModel.Insert(story)
+	if err != nil {
+		app.APIServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditStoryCreate, TargetType: "story", TargetID: story.ID, After: storySnapshot(story)})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/stories/%d", story.ID))
+
+	err = app.WriteJSON(w, http.StatusCreated, Envelope{"story": story}, headers)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
+
+// APIUpdateStoryHandler replaces the title and content of a story the authenticated user may edit.
+func (app *Application) APIUpdateStoryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.APIClientError(w, http.StatusNotFound)
+		return
+	}
+
+	story, err := app.StoryModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.APIClientError(w, http.StatusNotFound)
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+
+	if !Can(user, ActionEditStory, story) {
+		app.APIClientError(w, http.StatusForbidden)
+		return
+	}
+
+	var input storyInput
+	err = app.ReadJSON(w, r, &input)
+	if err != nil {
+		app.APIBadRequest(w, err)
+		return
+	}
+
+	if input.Status == "" {
+		input.Status = story.Status
+	}
+	if input.ScheduledAt == nil {
+		input.ScheduledAt = story.ScheduledAt
+	}
+	tags := story.Tags
+	if input.Tags != nil {
+		tags = data.NormalizeTags(input.Tags)
+	}
+
+	v := NewValidator()
+	ValidateStory(v, input.Title, input.Content, input.Status)
+	ValidateSchedule(v, input.Status, input.ScheduledAt, app.Clock.Now())
+	ValidateTags(v, tags)
+	if !v.Valid() {
+		app.APIFailedValidation(w, v.Errors)
+		return
+	}
+
+	before := storySnapshot(story)
+	story.Title = input.Title
+	story.Content = input.Content
+	story.Status = input.Status
+	story.ScheduledAt = input.ScheduledAt
+	story.Tags = tags
+	if input.Version != nil {
+		story.Version = *input.Version
+	}
+
+	err = app.StoryModel.Update(story, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.APIErrorResponse(w, http.StatusConflict, "the story has been changed since this version; fetch it again and reapply your edit")
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.APIClientError(w, http.StatusNotFound)
+		default:
+			app.APIServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditStoryUpdate, TargetType: "story", TargetID: story.ID, Before: before, After: storySnapshot(story)})
+
+	err = app.WriteJSON(w, http.StatusOK, Envelope{"story": story}, nil)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
+
+// APIDeleteStoryHandler moves a story the authenticated user may delete to its author's trash.
+func (app *Application) APIDeleteStoryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	id, err := readIDParam(r)
+	if err != nil {
+		app.APIClientError(w, http.StatusNotFound)
+		return
+	}
+
+	story, err := app.StoryModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.APIClientError(w, http.StatusNotFound)
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+
+	if !Can(user, ActionDeleteStory, story) {
+		app.APIClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.StoryModel.SoftDelete(story.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.APIClientError(w, http.StatusNotFound)
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditStoryDelete, TargetType: "story", TargetID: story.ID, Before: storySnapshot(story)})
+
+	err = app.WriteJSON(w, http.StatusOK, Envelope{"message": "story moved to trash"}, nil)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
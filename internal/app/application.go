@@ -3,10 +3,15 @@ package app
 import (
 	"database/sql"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/RudyItza/ahsehdis/internal/clock"
 	"github.com/RudyItza/ahsehdis/internal/data"
-	"github.com/gorilla/sessions"
+	"github.com/RudyItza/ahsehdis/internal/mailer"
+	"github.com/RudyItza/ahsehdis/internal/ratelimit"
+	"github.com/RudyItza/ahsehdis/internal/spelling"
 )
 
 // Application holds shared dependencies for the web application.
@@ -14,19 +19,56 @@ type Application struct {
 	ErrorLog     *log.Logger
 	InfoLog      *log.Logger
 	DB           *sql.DB
-	SessionStore *sessions.CookieStore
+	SessionStore *PGStore
+	SessionModel *data.SessionModel
 	UserModel    *data.UserModel
 	StoryModel   *data.StoryModel
+	TokenModel   *data.TokenModel
 	CSRFKey      []byte // Key used for CSRF protection
+
+	OneTimeTokenModel *data.OneTimeTokenModel
+	Mailer            mailer.Mailer
+	BaseURL           string // Public URL used to build links in emails, without a trailing slash
+
+	RequireVerifiedToPublish bool   // Only users with a verified email address may publish stories
+	TOTPKey                  []byte // 32-byte AES key used to encrypt TOTP secrets at rest
+	LoginThrottle            *LoginThrottle
+
+	Limiter        *ratelimit.Limiter
+	RateLimits     map[string]ratelimit.Rate // Rate for each policy passed to RateLimit
+	TrustedProxies []*net.IPNet              // Proxies whose X-Forwarded-For header is believed
+
+	PasswordPolicy *PasswordPolicy // Rules for new passwords at signup, change and reset
+	AuditModel     *data.AuditModel
+
+	ReportModel     *data.ReportModel
+	ReportThreshold int // Open reports that hide a story until a moderator reviews it (0 to disable)
+
+	TagModel *data.TagModel
+	Spelling *spelling.Dictionary // Spelling variants accepted by story searches
+
+	CommentModel *data.CommentModel
+
+	TrashRetention time.Duration // How long deleted stories stay in the trash (0 keeps them forever)
+
+	Clock clock.Clock // Source of the current time for scheduling stories
 }
 
 const (
 	SessionName    = "ahsehdis-session"    // Name of the session cookie
 	SessionUserKey = "authenticatedUserID" // Key used to store/retrieve user ID in session
+
+	SessionTwoFactorUserKey    = "twoFactorUserID"    // User who passed the password step and still owes a second factor
+	SessionTwoFactorStartedKey = "twoFactorStartedAt" // Unix time the password step succeeded
+	SessionPendingTOTPKey      = "pendingTOTPKeyURL"  // otpauth:// URL of a 2FA enrollment awaiting confirmation
 )
 
 // InvalidCSRFHandler responds with 403 Forbidden for invalid or missing CSRF tokens
 func (app *Application) InvalidCSRFHandler(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		app.APIErrorResponse(w, http.StatusForbidden, "invalid or missing CSRF token")
+		return
+	}
 	app.ClientError(w, http.StatusForbidden)
 }
 
@@ -49,3 +91,10 @@ func (app *Application) ContextGetUser(r *http.Request) *data.User {
 	}
 	return user // Return the authenticated user
 }
+
+// ContextGetTokenScope returns the scope of the bearer token used for the request,
+// or an empty string when the request was authenticated by session cookie
+func (app *Application) ContextGetTokenScope(r *http.Request) string {
+	scope, _ := r.Context().Value("tokenScope").(string)
+	return scope
+}
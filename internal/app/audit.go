@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// auditEntry describes an event for audit. Before and After are snapshots of
+// the target; only the fields that differ between them are recorded.
+type auditEntry struct {
+	Action     string
+	Actor      *data.User // defaults to the signed-in user
+	TargetType string     // one of data.AuditTargetTypes
+	TargetID   interface{}
+	Before     map[string]interface{}
+	After      map[string]interface{}
+	Metadata   map[string]interface{}
+}
+
+// audit appends an event to the audit log, recording who did it and from where.
+// Failing to record an event is logged but does not fail the request.
+func (app *Application) audit(r *http.Request, entry auditEntry) {
+	actor := entry.Actor
+	if actor == nil {
+		actor = app.ContextGetUser(r)
+	}
+
+	event := &data.AuditEvent{
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		IP:         app.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+	if actor != nil {
+		event.ActorID = &actor.ID
+		event.ActorEmail = actor.Email
+	}
+	if entry.TargetID != nil {
+		event.TargetID = fmt.Sprint(entry.TargetID)
+	}
+	if scope := app.ContextGetTokenScope(r); scope != "" {
+		if entry.Metadata == nil {
+			entry.Metadata = map[string]interface{}{}
+		}
+		entry.Metadata["via_token"] = scope
+	}
+
+	before, after := auditDiff(entry.Before, entry.After)
+	fields := []struct {
+		dst *json.RawMessage
+		src map[string]interface{}
+	}{{&event.Before, before}, {&event.After, after}, {&event.Metadata, entry.Metadata}}
+	for _, field := range fields {
+		if field.src == nil {
+			continue
+		}
+		raw, err := json.Marshal(field.src)
+		if err != nil {
+			app.ErrorLog.Output(2, fmt.Sprintf("audit %s: %v", entry.Action, err))
+			return
+		}
+		*field.dst = raw
+	}
+
+	if err := app.AuditModel.Insert(event); err != nil {
+		app.ErrorLog.Output(2, fmt.Sprintf("audit %s: %v", entry.Action, err))
+	}
+}
+
+// auditDiff keeps only the fields whose values differ between two snapshots.
+// When either snapshot is missing (a create or delete) the other is kept whole.
+func auditDiff(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	if before == nil || after == nil {
+		return before, after
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range before {
+		if !reflect.DeepEqual(value, after[key]) {
+			changedBefore[key] = value
+			changedAfter[key] = after[key]
+		}
+	}
+	for key, value := range after {
+		if _, seen := before[key]; !seen {
+			changedBefore[key] = nil
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// storySnapshot captures the audited fields of a story.
+func storySnapshot(story *data.Story) map[string]interface{} {
+	return map[string]interface{}{
+		"title":   story.Title,
+		"content": story.Content,
+		"status":  story.Status,
+		"tags":    story.Tags,
+		"user_id": story.UserID,
+	}
+}
+
+// userSnapshot captures the fields of a user that admins can change.
+func userSnapshot(user *data.User) map[string]interface{} {
+	return map[string]interface{}{
+		"role":                    user.Role,
+		"disabled":                user.IsDisabled(),
+		"password_reset_required": user.PasswordResetRequired,
+	}
+}
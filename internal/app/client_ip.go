@@ -0,0 +1,79 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// ranges, e.g. "10.0.0.0/8,127.0.0.1".
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP returns the address of the client that made the request. The
+// X-Forwarded-For header is only honoured when the connection comes from a
+// trusted proxy, in which case the header is read right to left and the first
+// address that is not itself a trusted proxy is the client. Anything to the
+// left of that was supplied by the client and cannot be believed.
+func (app *Application) ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !app.isTrustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// A malformed entry means the rest of the chain cannot be trusted
+			break
+		}
+		ip = hop
+		if !app.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// isTrustedProxy reports whether ip belongs to one of the TrustedProxies ranges.
+func (app *Application) isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range app.TrustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
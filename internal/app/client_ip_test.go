@@ -0,0 +1,54 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &Application{TrustedProxies: proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{"no proxy", "198.51.100.7:4321", nil, "198.51.100.7"},
+		{"untrusted peer's header ignored", "198.51.100.7:4321", []string{"203.0.113.9"}, "198.51.100.7"},
+		{"trusted proxy", "10.1.2.3:80", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"trusted proxy without header", "10.1.2.3:80", nil, "10.1.2.3"},
+		{"single trusted address", "192.0.2.1:80", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"chain of trusted proxies", "10.1.2.3:80", []string{"203.0.113.9, 10.9.9.9, 10.0.0.1"}, "203.0.113.9"},
+		{"spoofed hop before an untrusted one", "10.1.2.3:80", []string{"1.1.1.1, 203.0.113.9"}, "203.0.113.9"},
+		{"repeated headers joined", "10.1.2.3:80", []string{"203.0.113.9", "10.0.0.1"}, "203.0.113.9"},
+		{"malformed hop stops the walk", "10.1.2.3:80", []string{"203.0.113.9, garbage, 10.0.0.1"}, "10.0.0.1"},
+		{"ipv6 proxy", "[2001:db8::1]:80", []string{"2001:db8:ffff::2, 2606:4700::1"}, "2606:4700::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := app.ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, s := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.1,,bogus/8"} {
+		if _, err := ParseTrustedProxies(s); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) returned no error", s)
+		}
+	}
+}
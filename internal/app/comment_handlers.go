@@ -0,0 +1,356 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// renderStory shows a story page with its comment thread. form holds a comment
+// form being shown again with errors, and may be nil.
+func (app *Application) renderStory(w http.ResponseWriter, r *http.Request, story *data.Story, form map[string]interface{}) {
+	comments, err := app.CommentModel.Thread(story.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// Deleted comments kept in the thread for their replies are not counted
+	count := 0
+	for _, comment := range comments {
+		if !comment.IsDeleted() {
+			count++
+		}
+	}
+
+	td := map[string]interface{}{
+		"Story":            story,
+		"Meta":             app.storyMeta(story),
+		"Comments":         comments,
+		"CommentCount":     count,
+		"MaxCommentLength": data.MaxCommentLength,
+	}
+	for key, value := range form {
+		td[key] = value
+	}
+	app.Render(w, r, "story.tmpl", td)
+}
+
+// CreateCommentHandler posts a comment on the story named by ?id=, or a reply
+// to one of its comments when the form names a parent_id.
+func (app *Application) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.commentableStory(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	body := strings.TrimSpace(r.PostForm.Get("body"))
+
+	v := NewValidator()
+	ValidateComment(v, body)
+
+	// A reply must answer a comment that is still there, on the same story
+	var parent *data.Comment
+	if raw := r.PostForm.Get("parent_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id < 1 {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		parent, err = app.CommentModel.Get(id)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				app.ClientError(w, http.StatusBadRequest)
+			} else {
+				app.ServerError(w, err)
+			}
+			return
+		}
+		if parent.StoryID != story.ID {
+			app.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		v.Check(!parent.IsDeleted(), "body", "The comment you are replying to has been deleted")
+	}
+
+	if !v.Valid() {
+		app.renderStory(w, r, story, map[string]interface{}{
+			"CommentErrors": v.Errors,
+			"CommentBody":   body,
+			"ReplyTo":       parent,
+		})
+		return
+	}
+
+	comment := &data.Comment{
+		StoryID: story.ID,
+		UserID:  user.ID,
+		Body:    body,
+	}
+	if parent != nil {
+		comment.ParentID = &parent.ID
+	}
+	err = app.CommentModel.Insert(comment)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditCommentCreate,
+		TargetType: "comment",
+		TargetID:   comment.ID,
+		Metadata:   map[string]interface{}{"story_id": story.ID, "parent_id": comment.ParentID},
+	})
+
+	app.flashRedirect(w, r, "Comment posted.", commentURL(story, comment))
+}
+
+// EditCommentForm displays the form to edit one of the user's own comments.
+func (app *Application) EditCommentForm(w http.ResponseWriter, r *http.Request) {
+	comment, story, ok := app.editableComment(w, r)
+	if !ok {
+		return
+	}
+
+	app.Render(w, r, "edit_comment.tmpl", map[string]interface{}{
+		"Story":            story,
+		"Comment":          comment,
+		"Body":             comment.Body,
+		"MaxCommentLength": data.MaxCommentLength,
+	})
+}
+
+// UpdateCommentHandler saves the new text of one of the user's own comments.
+func (app *Application) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, story, ok := app.editableComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	body := strings.TrimSpace(r.PostForm.Get("body"))
+
+	v := NewValidator()
+	ValidateComment(v, body)
+
+	if !v.Valid() {
+		app.Render(w, r, "edit_comment.tmpl", map[string]interface{}{
+			"Story":            story,
+			"Comment":          comment,
+			"Body":             body,
+			"Errors":           v.Errors,
+			"MaxCommentLength": data.MaxCommentLength,
+		})
+		return
+	}
+
+	before := comment.Body
+	comment.Body = body
+	err = app.CommentModel.Update(comment)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditCommentUpdate,
+		TargetType: "comment",
+		TargetID:   comment.ID,
+		Before:     map[string]interface{}{"body": before},
+		After:      map[string]interface{}{"body": comment.Body},
+		Metadata:   map[string]interface{}{"story_id": story.ID},
+	})
+
+	app.flashRedirect(w, r, "Comment updated.", commentURL(story, comment))
+}
+
+// DeleteCommentHandler deletes a comment, by its author or a moderator. Replies
+// to it stay in the thread under a placeholder.
+func (app *Application) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, story, ok := app.commentFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if !Can(app.ContextGetUser(r), ActionDeleteComment, comment) {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.CommentModel.Delete(comment.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditCommentDelete,
+		TargetType: "comment",
+		TargetID:   comment.ID,
+		Before:     map[string]interface{}{"body": comment.Body, "user_id": comment.UserID},
+		Metadata:   map[string]interface{}{"story_id": story.ID},
+	})
+
+	app.flashRedirect(w, r, "Comment deleted.", storyCommentsURL(story))
+}
+
+// LockCommentsHandler stops new comments on a story. Existing comments stay visible.
+func (app *Application) LockCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	app.setCommentsLocked(w, r, true)
+}
+
+// UnlockCommentsHandler lets readers comment on a locked story again.
+func (app *Application) UnlockCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	app.setCommentsLocked(w, r, false)
+}
+
+// setCommentsLocked locks or unlocks comments on the story named by ?id=, for
+// its author or a moderator.
+func (app *Application) setCommentsLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if !Can(user, ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if !Can(user, ActionLockComments, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := app.StoryModel.SetCommentsLocked(story.ID, locked)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	action, message := data.AuditStoryCommentsLock, "Comments locked. Readers can no longer comment on this story."
+	if !locked {
+		action, message = data.AuditStoryCommentsUnlock, "Comments unlocked."
+	}
+	app.audit(r, auditEntry{Action: action, TargetType: "story", TargetID: story.ID})
+
+	app.flashRedirect(w, r, message, storyCommentsURL(story))
+}
+
+// commentableStory loads the story named by the ?id= parameter and checks the
+// current user may comment on it. It writes the error response and returns false on failure.
+func (app *Application) commentableStory(w http.ResponseWriter, r *http.Request) (*data.Story, bool) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !Can(user, ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return nil, false
+	}
+	if !Can(user, ActionCommentStory, story) {
+		if story.CommentsLocked() {
+			app.flashRedirect(w, r, "Comments on this story are locked.", storyCommentsURL(story))
+		} else {
+			app.ClientError(w, http.StatusForbidden)
+		}
+		return nil, false
+	}
+	return story, true
+}
+
+// editableComment loads the comment named by the ?id= parameter and checks the
+// current user may edit it: it is theirs and its story still takes comments.
+// It writes the error response and returns false on failure.
+func (app *Application) editableComment(w http.ResponseWriter, r *http.Request) (*data.Comment, *data.Story, bool) {
+	user := app.ContextGetUser(r)
+
+	comment, story, ok := app.commentFromQuery(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if !Can(user, ActionEditComment, comment) {
+		app.ClientError(w, http.StatusForbidden)
+		return nil, nil, false
+	}
+	if !Can(user, ActionCommentStory, story) {
+		app.flashRedirect(w, r, "Comments on this story are locked.", commentURL(story, comment))
+		return nil, nil, false
+	}
+	return comment, story, true
+}
+
+// commentFromQuery loads the comment named by the ?id= parameter and its story,
+// treating comments on stories the user may not see as missing. It writes the
+// error response and returns false on failure.
+func (app *Application) commentFromQuery(w http.ResponseWriter, r *http.Request) (*data.Comment, *data.Story, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id < 1 {
+		app.ClientError(w, http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	comment, err := app.CommentModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	story, err := app.StoryModel.Get(comment.StoryID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	if !Can(app.ContextGetUser(r), ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return nil, nil, false
+	}
+	return comment, story, true
+}
+
+// storyCommentsURL links to the comments section of a story's page
+func storyCommentsURL(story *data.Story) string {
+	return story.Path() + "#comments"
+}
+
+// commentURL links to a comment on its story's page
+func commentURL(story *data.Story, comment *data.Comment) string {
+	return fmt.Sprintf("%s#comment-%d", story.Path(), comment.ID)
+}
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/RudyItza/ahsehdis/internal/worddiff"
 )
 
 // HomeHandler displays the homepage with the 10 latest stories.
@@ -40,35 +42,113 @@ func (app *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.PostForm.Get("password")
 
 	user, err := app.UserModel.GetByEmail(email)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.ServerError(w, err)
+		return
+	}
+
+	// Refuse the attempt outright if this IP, email or account is being throttled
+	ip := app.ClientIP(r)
+	wait, err := app.LoginThrottle.Check(ip, email, user)
 	if err != nil {
-		if errors.Is(err, data.ErrRecordNotFound) {
-			// Show invalid credentials if user not found
+		app.ServerError(w, err)
+		return
+	}
+	if wait > 0 {
+		app.Render(w, r, "login.tmpl", map[string]interface{}{
+			"Error": "Too many login attempts. Please try again in " + formatWait(wait) + ".",
+			"Email": email,
+		})
+		return
+	}
 
-			app.Render(w, r, "login.tmpl", map[string]interface{}{
-				"Error": "Invalid credentials",
-			})
+	// Show invalid credentials if user not found or the password is wrong
+	if user == nil || user.MatchesPassword(password) != nil {
+		if err := app.LoginThrottle.Failure(ip, email, user); err != nil {
+			app.ServerError(w, err)
 			return
 		}
-		app.ServerError(w, err)
+		app.auditLoginFailure(r, email, user, "password")
+		app.Render(w, r, "login.tmpl", map[string]interface{}{
+			"Error": "Invalid credentials",
+			"Email": email,
+		})
 		return
 	}
 
-	// Compare passwords
-	err = user.MatchesPassword(password)
-	if err != nil {
+	// A correct password is not enough if an admin has stepped in
+	if user.IsDisabled() {
+		app.auditLoginFailure(r, email, user, "disabled")
 		app.Render(w, r, "login.tmpl", map[string]interface{}{
-			"Error": "Invalid credentials",
+			"Error": "This account has been disabled.",
+			"Email": email,
 		})
 		return
 	}
+	if user.PasswordResetRequired {
+		app.auditLoginFailure(r, email, user, "password_reset_required")
+		app.Render(w, r, "login.tmpl", map[string]interface{}{
+			"Error": "You need to choose a new password. Use the link we emailed you, or request a new one with \"Forgot your password?\".",
+			"Email": email,
+		})
+		return
+	}
+
+	// The plaintext is only available now, so upgrade bcrypt or outdated argon2id
+	// hashes while we have it. A failure here should not stop the login.
+	if user.PasswordNeedsRehash() {
+		if err := app.rehashPassword(user, password); err != nil {
+			app.ErrorLog.Printf("rehash password for user %d: %v", user.ID, err)
+		}
+	}
+
+	// With 2FA on, the password only gets the user to the second step: remember
+	// who is logging in without authenticating the session yet
+	if user.TOTPEnabled() {
+		session, err := app.SessionStore.Get(r, SessionName)
+		if err != nil {
+			app.ServerError(w, err)
+			return
+		}
+
+		session.Values[SessionTwoFactorUserKey] = user.ID
+		session.Values[SessionTwoFactorStartedKey] = time.Now().Unix()
+		if err := session.Save(r, w); err != nil {
+			app.ServerError(w, err)
+			return
+		}
+
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if err := app.LoginThrottle.Success(email, user); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditLogin, Actor: user, TargetType: "user", TargetID: user.ID})
+
 	// Create a session and store user ID
+	app.logIn(w, r, user.ID)
+}
+
+// logIn authenticates the session as the given user on a fresh session token and redirects home.
+func (app *Application) logIn(w http.ResponseWriter, r *http.Request, userID int) {
 	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
 		app.ServerError(w, err)
 		return
 	}
+	// Issue a fresh session token on login to prevent session fixation
+	if err := app.SessionStore.Renew(session); err != nil {
+		app.ServerError(w, err)
+		return
+	}
 
-	session.Values[SessionUserKey] = user.ID
+	delete(session.Values, SessionTwoFactorUserKey)
+	delete(session.Values, SessionTwoFactorStartedKey)
+	session.Values[SessionUserKey] = userID
 	if err := session.Save(r, w); err != nil {
 		app.ServerError(w, err)
 		return
@@ -77,6 +157,39 @@ func (app *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// auditLoginFailure records a refused login. user is nil when the email does
+// not belong to an account; a failure that locked the account is recorded too.
+func (app *Application) auditLoginFailure(r *http.Request, email string, user *data.User, reason string) {
+	entry := auditEntry{
+		Action:   data.AuditLoginFailed,
+		Metadata: map[string]interface{}{"email": email, "reason": reason},
+	}
+	if user == nil {
+		app.audit(r, entry)
+		return
+	}
+
+	entry.TargetType, entry.TargetID = "user", user.ID
+	app.audit(r, entry)
+
+	if user.IsLocked(time.Now()) {
+		app.audit(r, auditEntry{
+			Action:     data.AuditLocked,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Metadata:   map[string]interface{}{"failures": user.FailedLoginCount, "locked_until": user.LockedUntil},
+		})
+	}
+}
+
+// rehashPassword replaces the user's stored hash with one from the current password hasher.
+func (app *Application) rehashPassword(user *data.User, password string) error {
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	return app.UserModel.UpdatePassword(user)
+}
+
 // SignupForm displays the signup form.
 func (app *Application) SignupForm(w http.ResponseWriter, r *http.Request) {
 	app.Render(w, r, "signup.tmpl", nil)
@@ -96,8 +209,10 @@ func (app *Application) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	v := NewValidator()
 	v.Check(NotBlank(email), "email", "Email is required")
 	v.Check(ValidateEmail(email), "email", "Invalid email format")
-	v.Check(NotBlank(password), "password", "Password is required")
-	v.Check(len(password) >= 8, "password", "Password must be at least 8 characters")
+	if err := app.PasswordPolicy.Validate(v, password, email); err != nil {
+		app.ServerError(w, err)
+		return
+	}
 
 	if !v.Valid() {
 		app.Render(w, r, "signup.tmpl", map[string]interface{}{
@@ -127,14 +242,27 @@ func (app *Application) SignupHandler(w http.ResponseWriter, r *http.Request) {
 		app.ServerError(w, err)
 		return
 	}
+	app.audit(r, auditEntry{Action: data.AuditSignup, Actor: user, TargetType: "user", TargetID: user.ID})
+
+	// Email a link to confirm the address
+	err = app.sendVerificationEmail(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
 	// Auto-login the user after signup
-	session, err := app.SessionStore.New(r, SessionName)
+	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
 		app.ServerError(w, err)
 		return
 	}
+	if err := app.SessionStore.Renew(session); err != nil {
+		app.ServerError(w, err)
+		return
+	}
 
 	session.Values[SessionUserKey] = user.ID
+	session.AddFlash("Welcome! We have sent you an email to verify your address.")
 	if err := session.Save(r, w); err != nil {
 		app.ServerError(w, err)
 		return
@@ -145,7 +273,9 @@ func (app *Application) SignupHandler(w http.ResponseWriter, r *http.Request) {
 
 // SubmitStoryForm displays the form to submit a new story.
 func (app *Application) SubmitStoryForm(w http.ResponseWriter, r *http.Request) {
-	app.Render(w, r, "submit_story.tmpl", nil)
+	app.Render(w, r, "submit_story.tmpl", map[string]interface{}{
+		"Status": data.StatusPublished,
+	})
 }
 
 // SubmitStoryHandler processes new story submissions.
@@ -164,26 +294,34 @@ func (app *Application) SubmitStoryHandler(w http.ResponseWriter, r *http.Reques
 
 	title := r.PostForm.Get("title")
 	content := r.PostForm.Get("content")
+	status := r.PostForm.Get("status")
+	tags := data.ParseTags(r.PostForm.Get("tags"))
 	// Validate story fields
 	v := NewValidator()
-	v.Check(NotBlank(title), "title", "Title is required")
-	v.Check(len(title) >= 10 && len(title) <= 20, "title", "Title must be between 10-20 characters")
-	v.Check(NotBlank(content), "content", "Content is required")
-	v.Check(len(content) <= 500, "content", "Content must be 500 characters or less")
+	scheduledAt := readScheduledAt(r, v)
+	ValidateStory(v, title, content, status)
+	ValidateSchedule(v, status, scheduledAt, app.Clock.Now())
+	ValidateTags(v, tags)
 
 	if !v.Valid() {
 		app.Render(w, r, "submit_story.tmpl", map[string]interface{}{
-			"Errors":  v.Errors,
-			"Title":   title,
-			"Content": content,
+			"Errors":      v.Errors,
+			"Title":       title,
+			"Content":     content,
+			"Status":      status,
+			"ScheduledAt": scheduledAt,
+			"Tags":        tags,
 		})
 		return
 	}
 	// Insert story into DB
 	story := &data.Story{
-		Title:   title,
-		Content: content,
-		UserID:  user.ID,
+		Title:       title,
+		Content:     content,
+		UserID:      user.ID,
+		Status:      status,
+		ScheduledAt: scheduledAt,
+		Tags:        tags,
 	}
 
 	err = app.StoryModel.Insert(story)
@@ -191,6 +329,7 @@ func (app *Application) SubmitStoryHandler(w http.ResponseWriter, r *http.Reques
 		app.ServerError(w, err)
 		return
 	}
+	app.audit(r, auditEntry{Action: data.AuditStoryCreate, TargetType: "story", TargetID: story.ID, After: storySnapshot(story)})
 	// Show flash message after successful submission
 	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
@@ -198,57 +337,103 @@ func (app *Application) SubmitStoryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	session.AddFlash("Story created successfully!")
+	switch story.Status {
+	case data.StatusPublished:
+		session.AddFlash("Story created successfully!")
+	case data.StatusScheduled:
+		session.AddFlash("Story scheduled for " + story.ScheduledAt.In(time.Local).Format("02 Jan 2006 at 15:04 MST") + ".")
+	default:
+		session.AddFlash("Story saved. You can find it under My Stories.")
+	}
 	if err := session.Save(r, w); err != nil {
 		app.ServerError(w, err)
 		return
 	}
 
-	http.Redirect(w, r, "/stories", http.StatusSeeOther)
+	http.Redirect(w, r, storyReturnTo(story), http.StatusSeeOther)
+}
+
+// readCursorFilters reads the after and before cursors of a story listing from the query string.
+func readCursorFilters(r *http.Request, pageSize int) data.CursorFilters {
+	qs := r.URL.Query()
+	return data.CursorFilters{
+		After:    qs.Get("after"),
+		Before:   qs.Get("before"),
+		PageSize: pageSize,
+	}
 }
 
 // ViewStoriesHandler displays paginated list of stories.
 func (app *Application) ViewStoriesHandler(w http.ResponseWriter, r *http.Request) {
 	const storiesPerPage = 10
 
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil || page < 1 {
-		page = 1
+	stories, metadata, err := app.StoryModel.ListPublished(readCursorFilters(r, storiesPerPage))
+	if err != nil {
+		if errors.Is(err, data.ErrInvalidCursor) {
+			app.ClientError(w, http.StatusBadRequest)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
 	}
 
-	stories, err := app.StoryModel.GetAllPaginated(page, storiesPerPage)
+	app.Render(w, r, "view_stories.tmpl", map[string]interface{}{
+		"Stories":  stories,
+		"Metadata": metadata,
+	})
+}
+
+// ShowStoryHandler displays a single story at /story/{id}/{slug} to anyone allowed
+// to see it. Unlisted stories are only reachable this way, through a link shared
+// by their author. A missing or out-of-date slug is redirected to the canonical URL.
+func (app *Application) ShowStoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
 	if err != nil {
-		app.ServerError(w, err)
+		app.ClientError(w, http.StatusNotFound)
 		return
 	}
 
-	totalStories, err := app.StoryModel.GetTotalCount()
+	story, err := app.StoryModel.Get(id)
 	if err != nil {
-		app.ServerError(w, err)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
 		return
 	}
 
-	totalPages := (totalStories + storiesPerPage - 1) / storiesPerPage
-	// Render stories with pagination info
-	data := map[string]interface{}{
-		"Stories": stories,
-		"Pagination": struct {
-			Current int
-			Total   int
-			HasNext bool
-			HasPrev bool
-		}{
-			Current: page,
-			Total:   totalPages,
-			HasNext: page < totalPages,
-			HasPrev: page > 1,
-		},
-	}
-
-	app.Render(w, r, "view_stories.tmpl", data)
+	// Stories the user may not see are reported as missing rather than forbidden
+	if !Can(app.ContextGetUser(r), ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	// The slug follows the title, so links made before a rename still lead here
+	if r.PathValue("slug") != story.Slug() {
+		http.Redirect(w, r, story.Path(), http.StatusMovedPermanently)
+		return
+	}
+
+	app.renderStory(w, r, story, nil)
 }
 
-// LogoutHandler logs the user out by clearing session data.
+// LegacyShowStoryHandler permanently redirects the old /story/view?id= links
+// to the story's canonical URL.
+func (app *Application) LegacyShowStoryHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+	if !Can(app.ContextGetUser(r), ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, story.Path(), http.StatusMovedPermanently)
+}
+
+// LogoutHandler logs the user out by deleting the server-side session.
 func (app *Application) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
@@ -256,7 +441,12 @@ func (app *Application) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	delete(session.Values, SessionUserKey)
+	if user := app.ContextGetUser(r); user != nil {
+		app.audit(r, auditEntry{Action: data.AuditLogout, TargetType: "user", TargetID: user.ID})
+	}
+
+	// A negative MaxAge removes the session row and clears the cookie
+	session.Options.MaxAge = -1
 	if err := session.Save(r, w); err != nil {
 		app.ServerError(w, err)
 		return
@@ -265,7 +455,7 @@ func (app *Application) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// EditStoryForm displays the form to edit a story (only by the owner).
+// EditStoryForm displays the form to edit a story (by its owner or a moderator).
 func (app *Application) EditStoryForm(w http.ResponseWriter, r *http.Request) {
 	user := app.ContextGetUser(r)
 	if user == nil {
@@ -289,7 +479,7 @@ func (app *Application) EditStoryForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if story.UserID != user.ID {
+	if !Can(user, ActionEditStory, story) {
 		app.ClientError(w, http.StatusForbidden)
 		return
 	}
@@ -299,7 +489,7 @@ func (app *Application) EditStoryForm(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// EditStoryHandler processes editing of a story by the owner.
+// EditStoryHandler processes editing of a story by its owner or a moderator.
 func (app *Application) EditStoryHandler(w http.ResponseWriter, r *http.Request) {
 	user := app.ContextGetUser(r)
 	if user == nil {
@@ -318,6 +508,12 @@ func (app *Application) EditStoryHandler(w http.ResponseWriter, r *http.Request)
 		app.ClientError(w, http.StatusBadRequest)
 		return
 	}
+	// The version the form was loaded at, so edits made elsewhere in the meantime are not overwritten
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil || version < 1 {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
 
 	existingStory, err := app.StoryModel.Get(id)
 	if err != nil {
@@ -329,40 +525,53 @@ func (app *Application) EditStoryHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if existingStory.UserID != user.ID {
+	if !Can(user, ActionEditStory, existingStory) {
 		app.ClientError(w, http.StatusForbidden)
 		return
 	}
 	// Get new values and validate
 	title := r.FormValue("title")
 	content := r.FormValue("content")
+	status := r.FormValue("status")
+	tags := data.ParseTags(r.FormValue("tags"))
 
 	v := NewValidator()
-	v.Check(NotBlank(title), "title", "Title is required")
-	v.Check(len(title) >= 10 && len(title) <= 20, "title", "Title must be between 10-20 characters")
-	v.Check(NotBlank(content), "content", "Content is required")
-	v.Check(len(content) <= 500, "content", "Content must be 500 characters or less")
+	scheduledAt := readScheduledAt(r, v)
+	ValidateStory(v, title, content, status)
+	ValidateSchedule(v, status, scheduledAt, app.Clock.Now())
+	ValidateTags(v, tags)
+
+	// Update story in DB; it keeps its original author even when a moderator edits it
+	before := storySnapshot(existingStory)
+	story := existingStory
+	story.Title = title
+	story.Content = content
+	story.Status = status
+	story.ScheduledAt = scheduledAt
+	story.Tags = tags
+	story.Version = version
 
 	if !v.Valid() {
 		app.Render(w, r, "edit_story.tmpl", map[string]interface{}{
-			"Story":  existingStory,
+			"Story":  story,
 			"Errors": v.Errors,
 		})
 		return
 	}
-	// Update story in DB
-	story := &data.Story{
-		ID:      id,
-		Title:   title,
-		Content: content,
-		UserID:  user.ID,
-	}
 
-	err = app.StoryModel.Update(story)
+	err = app.StoryModel.Update(story, user.ID)
 	if err != nil {
-		app.ServerError(w, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.renderEditConflict(w, r, story)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.ClientError(w, http.StatusNotFound)
+		default:
+			app.ServerError(w, err)
+		}
 		return
 	}
+	app.audit(r, auditEntry{Action: data.AuditStoryUpdate, TargetType: "story", TargetID: story.ID, Before: before, After: storySnapshot(story)})
 	// Flash success message and redirect
 	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
@@ -376,10 +585,31 @@ func (app *Application) EditStoryHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	http.Redirect(w, r, "/stories", http.StatusSeeOther)
+	http.Redirect(w, r, storyReturnTo(story), http.StatusSeeOther)
+}
+
+// renderEditConflict shows the user's rejected edit next to the version someone
+// else saved first, with a form to save a merged version on top of it.
+func (app *Application) renderEditConflict(w http.ResponseWriter, r *http.Request, mine *data.Story) {
+	current, err := app.StoryModel.Get(mine.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	app.RenderStatus(w, r, http.StatusConflict, "edit_conflict.tmpl", map[string]interface{}{
+		"Story":       current,
+		"Mine":        mine,
+		"TitleDiff":   worddiff.Diff(current.Title, mine.Title),
+		"ContentDiff": worddiff.Diff(current.Content, mine.Content),
+	})
 }
 
-// DeleteStoryHandler processes editing of a story by the owner.
+// DeleteStoryHandler moves a story to its author's trash, for its owner or a moderator.
 func (app *Application) DeleteStoryHandler(w http.ResponseWriter, r *http.Request) {
 	user := app.ContextGetUser(r)
 	if user == nil {
@@ -387,13 +617,28 @@ func (app *Application) DeleteStoryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
-	if err != nil || id < 1 {
+	id, err := readIDForm(r)
+	if err != nil {
 		app.ClientError(w, http.StatusBadRequest)
 		return
 	}
 
-	err = app.StoryModel.Delete(id, user.ID)
+	story, err := app.StoryModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	if !Can(user, ActionDeleteStory, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.StoryModel.SoftDelete(story.ID)
 	if err != nil {
 		if errors.Is(err, data.ErrRecordNotFound) {
 			app.ClientError(w, http.StatusNotFound)
@@ -402,6 +647,7 @@ func (app *Application) DeleteStoryHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
+	app.audit(r, auditEntry{Action: data.AuditStoryDelete, TargetType: "story", TargetID: story.ID, Before: storySnapshot(story)})
 
 	session, err := app.SessionStore.Get(r, SessionName)
 	if err != nil {
@@ -409,7 +655,7 @@ func (app *Application) DeleteStoryHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	session.AddFlash("Story deleted successfully!")
+	session.AddFlash("Story moved to the trash. You can restore it from your Trash page.")
 	if err := session.Save(r, w); err != nil {
 		app.ServerError(w, err)
 		return
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Envelope wraps JSON responses so every payload has a named top-level key.
+type Envelope map[string]interface{}
+
+// WriteJSON encodes data as JSON and writes it with the given status code and headers.
+func (app *Application) WriteJSON(w http.ResponseWriter, status int, data Envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// ReadJSON decodes a single JSON object from the request body into dst,
+// translating decoder errors into messages that are safe to return to clients.
+func (app *Application) ReadJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	// Limit the size of the request body to 1MB
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// Reject bodies that contain more than one JSON value
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// APIErrorResponse writes a JSON error envelope with the given status code.
+func (app *Application) APIErrorResponse(w http.ResponseWriter, status int, message interface{}) {
+	err := app.WriteJSON(w, status, Envelope{"error": message}, nil)
+	if err != nil {
+		app.ErrorLog.Output(2, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// APIServerError logs the error and responds with a generic 500 JSON error.
+func (app *Application) APIServerError(w http.ResponseWriter, err error) {
+	app.ErrorLog.Output(2, err.Error())
+	app.APIErrorResponse(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+// APIClientError responds with the standard status text for the given code as a JSON error.
+func (app *Application) APIClientError(w http.ResponseWriter, status int) {
+	app.APIErrorResponse(w, status, http.StatusText(status))
+}
+
+// APIBadRequest responds with 400 and the given error message.
+func (app *Application) APIBadRequest(w http.ResponseWriter, err error) {
+	app.APIErrorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+// APIFailedValidation responds with 422 and the field errors collected by a Validator.
+func (app *Application) APIFailedValidation(w http.ResponseWriter, errors map[string]string) {
+	app.APIErrorResponse(w, http.StatusUnprocessableEntity, errors)
+}
+
+// APINotFoundHandler is the catch-all for unknown API paths.
+func (app *Application) APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	app.APIClientError(w, http.StatusNotFound)
+}
+
+// isAPIRequest reports whether the request targets the JSON API.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/RudyItza/ahsehdis/internal/ratelimit"
+)
+
+// LoginFailureStore keeps each account's failed login history. *data.UserModel implements it.
+type LoginFailureStore interface {
+	RecordLoginFailure(user *data.User, at time.Time) error
+	Lock(user *data.User, until time.Time) error
+	ResetLoginFailures(user *data.User) error
+}
+
+// LoginThrottle slows down and eventually stops password guessing. Attempts
+// are rate limited per client IP and per email address, each failure against
+// a real account adds a growing delay before the next attempt, and too many
+// consecutive failures lock the account for a while.
+type LoginThrottle struct {
+	Limiter *ratelimit.Limiter
+	Users   LoginFailureStore
+	Clock   clock.Clock
+	Log     *log.Logger // receives an entry whenever a threshold trips
+
+	IPRate    ratelimit.Rate // attempts allowed per client IP
+	EmailRate ratelimit.Rate // failed attempts allowed per email address
+
+	DelayAfter int           // consecutive failures before delays start
+	BaseDelay  time.Duration // delay after the first failure past DelayAfter, doubling each time
+	MaxDelay   time.Duration
+
+	LockoutThreshold int           // consecutive failures that lock the account
+	LockoutDuration  time.Duration // length of the first lock, doubling for each further lock
+	MaxLockout       time.Duration
+}
+
+// NewLoginThrottle returns a throttle with the default policy.
+func NewLoginThrottle(limiter *ratelimit.Limiter, users LoginFailureStore, logger *log.Logger) *LoginThrottle {
+	return &LoginThrottle{
+		Limiter:          limiter,
+		Users:            users,
+		Clock:            limiter.Clock,
+		Log:              logger,
+		IPRate:           ratelimit.Rate{Burst: 20, Interval: 30 * time.Second},
+		EmailRate:        ratelimit.Rate{Burst: 5, Interval: 2 * time.Minute},
+		DelayAfter:       3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		MaxLockout:       24 * time.Hour,
+	}
+}
+
+// Check decides whether a login attempt may proceed before any password is
+// checked. user may be nil when the email does not belong to an account. When
+// the attempt is refused it returns how long the client should wait.
+func (t *LoginThrottle) Check(ip, email string, user *data.User) (time.Duration, error) {
+	now := t.Clock.Now()
+
+	// The IP bucket is spent on every attempt, successful or not
+	ipResult, err := t.Limiter.Allow(t.ipKey(ip), t.IPRate)
+	if err != nil {
+		return 0, err
+	}
+	if !ipResult.Allowed {
+		t.logf("login rate limit exceeded for ip=%s", ip)
+		return ipResult.RetryAfter, nil
+	}
+
+	// The email bucket is only spent on failures, so peek at it here
+	emailResult, err := t.Limiter.Peek(t.emailKey(email), t.EmailRate)
+	if err != nil {
+		return 0, err
+	}
+	if !emailResult.Allowed {
+		t.logf("login rate limit exceeded for email=%q ip=%s", email, ip)
+		return emailResult.RetryAfter, nil
+	}
+
+	if user == nil {
+		return 0, nil
+	}
+
+	if user.IsLocked(now) {
+		return user.LockedUntil.Sub(now), nil
+	}
+
+	if user.LastFailedLoginAt != nil {
+		if next := user.LastFailedLoginAt.Add(t.delay(user.FailedLoginCount)); now.Before(next) {
+			return next.Sub(now), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// Failure records a failed attempt (wrong password or second factor). user may be nil.
+func (t *LoginThrottle) Failure(ip, email string, user *data.User) error {
+	now := t.Clock.Now()
+
+	if _, err := t.Limiter.Hit(t.emailKey(email), t.EmailRate); err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	if err := t.Users.RecordLoginFailure(user, now); err != nil {
+		return err
+	}
+
+	// Lock the account each time another LockoutThreshold failures pile up
+	if t.LockoutThreshold > 0 && user.FailedLoginCount%t.LockoutThreshold == 0 {
+		lockouts := user.FailedLoginCount / t.LockoutThreshold
+		duration := backoff(t.LockoutDuration, lockouts-1, t.MaxLockout)
+		if err := t.Users.Lock(user, now.Add(duration)); err != nil {
+			return err
+		}
+		t.logf("account locked user_id=%d email=%q ip=%s failures=%d until=%s",
+			user.ID, user.Email, ip, user.FailedLoginCount, user.LockedUntil.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Success clears the failure history after a complete, successful login.
+func (t *LoginThrottle) Success(email string, user *data.User) error {
+	if err := t.Limiter.Reset(t.emailKey(email)); err != nil {
+		return err
+	}
+	return t.Users.ResetLoginFailures(user)
+}
+
+// delay is the minimum wait after the latest failure given the consecutive failure count.
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if failures < t.DelayAfter {
+		return 0
+	}
+	return backoff(t.BaseDelay, failures-t.DelayAfter, t.MaxDelay)
+}
+
+func (t *LoginThrottle) ipKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+func (t *LoginThrottle) emailKey(email string) string {
+	return "login:email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func (t *LoginThrottle) logf(format string, args ...interface{}) {
+	if t.Log != nil {
+		t.Log.Printf("SECURITY "+format, args...)
+	}
+}
+
+// backoff returns base doubled n times, capped at max.
+func backoff(base time.Duration, n int, max time.Duration) time.Duration {
+	if n < 0 {
+		n = 0
+	}
+	d := float64(base) * math.Pow(2, float64(n))
+	if d > float64(max) {
+		return max
+	}
+	return time.Duration(d)
+}
+
+// formatWait renders a wait time for users, rounding up to whole seconds or minutes.
+func formatWait(d time.Duration) string {
+	if d <= time.Minute {
+		seconds := int(math.Ceil(d.Seconds()))
+		if seconds <= 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+	minutes := int(math.Ceil(d.Minutes()))
+	return fmt.Sprintf("%d minutes", minutes)
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/RudyItza/ahsehdis/internal/ratelimit"
+)
+
+// memoryFailures keeps failed login history on the users themselves, as
+// *data.UserModel does once its queries return.
+type memoryFailures struct{}
+
+func (memoryFailures) RecordLoginFailure(user *data.User, at time.Time) error {
+	user.FailedLoginCount++
+	user.LastFailedLoginAt = &at
+	return nil
+}
+
+func (memoryFailures) Lock(user *data.User, until time.Time) error {
+	user.LockedUntil = &until
+	return nil
+}
+
+func (memoryFailures) ResetLoginFailures(user *data.User) error {
+	user.FailedLoginCount = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return nil
+}
+
+// newTestThrottle returns a throttle with the default policy driven by c.
+func newTestThrottle(c *clock.Manual) *LoginThrottle {
+	limiter := &ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), Clock: c}
+	return NewLoginThrottle(limiter, memoryFailures{}, nil)
+}
+
+func TestLoginThrottleAccountDelays(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		advance  time.Duration // between the last failure and the check
+		want     time.Duration
+	}{
+		{"below delay threshold", 2, 0, 0},
+		{"first delay", 3, 0, time.Second},
+		{"delay doubles", 5, 0, 4 * time.Second},
+		{"delay partly waited", 5, 3 * time.Second, time.Second},
+		{"delay waited out", 5, 4 * time.Second, 0},
+		{"delay capped", 9, 0, time.Minute},
+		{"first lockout", 10, 0, 15 * time.Minute},
+		{"lockout partly waited", 10, 5 * time.Minute, 10 * time.Minute},
+		{"lockout waited out", 10, 15 * time.Minute, 0},
+		{"lockout doubles", 20, 0, 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			throttle := newTestThrottle(c)
+			// Keep the email bucket out of the way so only the account's history counts
+			throttle.EmailRate = ratelimit.Rate{Burst: 100, Interval: time.Second}
+			user := &data.User{ID: 1, Email: "reader@example.com"}
+
+			for i := 0; i < tt.failures; i++ {
+				if err := throttle.Failure("192.0.2.1", user.Email, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.Advance(tt.advance)
+
+			wait, err := throttle.Check("192.0.2.1", user.Email, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait != tt.want {
+				t.Errorf("Check after %d failures and %s = %s; want %s", tt.failures, tt.advance, wait, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleSuccessResets(t *testing.T) {
+	c := clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	throttle := newTestThrottle(c)
+	user := &data.User{ID: 1, Email: "reader@example.com"}
+
+	for i := 0; i < 10; i++ {
+		if err := throttle.Failure("192.0.2.1", user.Email, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := throttle.Check("192.0.2.1", user.Email, user); wait == 0 {
+		t.Fatal("Check after 10 failures allowed the attempt; want it refused")
+	}
+
+	if err := throttle.Success(user.Email, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.FailedLoginCount != 0 || user.LastFailedLoginAt != nil || user.LockedUntil != nil {
+		t.Errorf("Success left failures=%d last=%v locked=%v; want them cleared",
+			user.FailedLoginCount, user.LastFailedLoginAt, user.LockedUntil)
+	}
+	wait, err := throttle.Check("192.0.2.1", user.Email, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != 0 {
+		t.Errorf("Check after Success = %s; want 0", wait)
+	}
+}
+
+func TestLoginThrottleRateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   int // attempts from one IP
+		failures int // failures for an email with no account
+		advance  time.Duration
+		refused  bool
+	}{
+		{"ip burst", 20, 0, 0, false},
+		{"ip over burst", 21, 0, 0, true},
+		{"ip refilled", 21, 0, 30 * time.Second, false},
+		{"email burst", 1, 4, 0, false},
+		{"email over burst", 1, 5, 0, true},
+		{"email refilled", 1, 5, 2 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			throttle := newTestThrottle(c)
+			email := "nobody@example.com"
+
+			for i := 0; i < tt.failures; i++ {
+				if err := throttle.Failure("192.0.2.1", email, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < tt.checks-1; i++ {
+				if _, err := throttle.Check("192.0.2.1", email, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.Advance(tt.advance)
+
+			wait, err := throttle.Check("192.0.2.1", email, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refused := wait > 0; refused != tt.refused {
+				t.Errorf("last Check waited %s; want refused=%t", wait, tt.refused)
+			}
+		})
+	}
+}
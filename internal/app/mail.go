@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+
+	"github.com/RudyItza/ahsehdis/internal/mailer"
+)
+
+// SendEmail renders the "subject" and "body" blocks of ui/email/<name> with
+// data and hands the message to the mailer in a background goroutine, so slow
+// mail servers never hold up a response. Failures are logged.
+func (app *Application) SendEmail(to, name string, data interface{}) {
+	ts, err := template.ParseFiles(filepath.Join("ui", "email", name))
+	if err != nil {
+		app.ErrorLog.Output(2, err.Error())
+		return
+	}
+
+	subject := new(bytes.Buffer)
+	if err := ts.ExecuteTemplate(subject, "subject", data); err != nil {
+		app.ErrorLog.Output(2, err.Error())
+		return
+	}
+
+	body := new(bytes.Buffer)
+	if err := ts.ExecuteTemplate(body, "body", data); err != nil {
+		app.ErrorLog.Output(2, err.Error())
+		return
+	}
+
+	msg := mailer.Message{To: to, Subject: subject.String(), Body: body.String()}
+
+	app.background(func() {
+		if err := app.Mailer.Send(msg); err != nil {
+			app.ErrorLog.Printf("sending %s to %s: %v", name, to, err)
+		}
+	})
+}
+
+// background runs fn in a new goroutine, recovering and logging any panic.
+func (app *Application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.ErrorLog.Output(2, fmt.Sprint(err))
+			}
+		}()
+		fn()
+	}()
+}
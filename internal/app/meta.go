@@ -0,0 +1,51 @@
+package app
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// metaDescriptionLength caps the description shown in link previews, in characters.
+const metaDescriptionLength = 200
+
+// pageMeta describes a page for link previews and search engines. The base
+// layout turns it into a canonical link and OpenGraph and Twitter card tags.
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string     // absolute canonical URL
+	Type        string     // OpenGraph type, such as "article"
+	PublishedAt *time.Time // for articles
+	NoIndex     bool       // asks search engines not to list the page
+}
+
+// storyMeta describes a story page. Only published stories are offered to
+// search engines; unlisted ones are for people with the link.
+func (app *Application) storyMeta(story *data.Story) pageMeta {
+	return pageMeta{
+		Title:       story.Title,
+		Description: metaDescription(story.Content),
+		URL:         app.BaseURL + story.Path(),
+		Type:        "article",
+		PublishedAt: story.PublishedAt,
+		NoIndex:     story.Status != data.StatusPublished || story.HiddenAt != nil,
+	}
+}
+
+// metaDescription flattens text onto one line and cuts it at a word boundary
+// to at most metaDescriptionLength characters, adding "…" when it was cut.
+func metaDescription(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= metaDescriptionLength {
+		return text
+	}
+
+	cut := []rune(text)[:metaDescriptionLength]
+	if i := strings.LastIndexByte(string(cut), ' '); i > 0 {
+		return string(cut)[:i] + "…"
+	}
+	return string(cut) + "…"
+}
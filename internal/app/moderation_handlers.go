@@ -0,0 +1,292 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// maxReportDetails caps the free-text part of a report.
+const maxReportDetails = 1000
+
+// ReportStoryForm asks the reader why they are reporting a story.
+func (app *Application) ReportStoryForm(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.reportableStory(w, r)
+	if !ok {
+		return
+	}
+
+	app.Render(w, r, "report_story.tmpl", map[string]interface{}{
+		"Story":        story,
+		"Reasons":      data.ReportReasons,
+		"ReasonLabels": data.ReportReasonLabels,
+	})
+}
+
+// ReportStoryHandler files a report against a story, hiding the story once it
+// has collected ReportThreshold open reports.
+func (app *Application) ReportStoryHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.reportableStory(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	reason := r.PostForm.Get("reason")
+	details := strings.TrimSpace(r.PostForm.Get("details"))
+
+	v := NewValidator()
+	v.Check(data.ValidReportReason(reason), "reason", "Please choose a reason")
+	v.Check(reason != data.ReportOther || NotBlank(details), "details", "Please tell us what is wrong with this story")
+	v.Check(len(details) <= maxReportDetails, "details", "Details must be 1000 characters or less")
+
+	if !v.Valid() {
+		app.Render(w, r, "report_story.tmpl", map[string]interface{}{
+			"Story":        story,
+			"Reasons":      data.ReportReasons,
+			"ReasonLabels": data.ReportReasonLabels,
+			"Errors":       v.Errors,
+			"Reason":       reason,
+			"Details":      details,
+		})
+		return
+	}
+
+	report := &data.Report{
+		StoryID:    story.ID,
+		ReporterID: user.ID,
+		Reason:     reason,
+		Details:    details,
+	}
+	err = app.ReportModel.Insert(report)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateReport) {
+			app.flashRedirect(w, r, "You have already reported this story. A moderator will review it.", "/stories")
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditStoryReport,
+		TargetType: "story",
+		TargetID:   story.ID,
+		Metadata:   map[string]interface{}{"reason": reason},
+	})
+
+	err = app.hideIfOverThreshold(r, story)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.flashRedirect(w, r, "Thanks for your report. A moderator will review it.", "/stories")
+}
+
+// hideIfOverThreshold hides a story once its open reports reach ReportThreshold.
+func (app *Application) hideIfOverThreshold(r *http.Request, story *data.Story) error {
+	if app.ReportThreshold <= 0 || story.HiddenAt != nil {
+		return nil
+	}
+
+	count, err := app.ReportModel.OpenCount(story.ID)
+	if err != nil {
+		return err
+	}
+	if count < app.ReportThreshold {
+		return nil
+	}
+
+	err = app.StoryModel.Hide(story.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return err
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditStoryHidden,
+		TargetType: "story",
+		TargetID:   story.ID,
+		Metadata:   map[string]interface{}{"open_reports": count, "threshold": app.ReportThreshold},
+	})
+	return nil
+}
+
+// reportableStory loads the story named by the ?id= parameter and checks the
+// current user may report it. It writes the error response and returns false on failure.
+func (app *Application) reportableStory(w http.ResponseWriter, r *http.Request) (*data.Story, bool) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !Can(user, ActionViewStory, story) {
+		app.ClientError(w, http.StatusNotFound)
+		return nil, false
+	}
+	if !Can(user, ActionReportStory, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return story, true
+}
+
+// ModerationQueueHandler lists stories with open reports, grouped per story.
+func (app *Application) ModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	filters := readFilters(r, "")
+
+	groups, metadata, err := app.ReportModel.Queue(filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "moderation.tmpl", map[string]interface{}{
+		"Groups":       groups,
+		"Metadata":     metadata,
+		"ReasonLabels": data.ReportReasonLabels,
+	})
+}
+
+// ModerationDismissHandler closes the reports against a story as unfounded,
+// putting the story back in listings if reports had hidden it.
+func (app *Application) ModerationDismissHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.resolveReports(w, r, story, data.ResolutionDismissed) {
+		return
+	}
+
+	if story.HiddenAt != nil {
+		err := app.StoryModel.Unhide(story.ID)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			app.ServerError(w, err)
+			return
+		}
+	}
+	app.audit(r, auditEntry{Action: data.AuditModDismiss, TargetType: "story", TargetID: story.ID})
+
+	app.flashRedirect(w, r, "Reports dismissed.", "/moderation")
+}
+
+// ModerationHideHandler closes the reports against a story and hides it.
+func (app *Application) ModerationHideHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.resolveReports(w, r, story, data.ResolutionStoryHidden) {
+		return
+	}
+
+	err := app.StoryModel.Hide(story.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditModHide, TargetType: "story", TargetID: story.ID, Before: storySnapshot(story)})
+
+	app.flashRedirect(w, r, "Story hidden.", "/moderation")
+}
+
+// ModerationSuspendHandler closes the reports against a story, hides it and
+// disables its author's account, signing them out everywhere.
+func (app *Application) ModerationSuspendHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	author, err := app.UserModel.GetByID(story.UserID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if !Can(app.ContextGetUser(r), ActionSuspendUser, author) {
+		app.flashRedirect(w, r, "You cannot suspend "+author.Email+". Hide the story or ask an admin.", "/moderation")
+		return
+	}
+
+	if !app.resolveReports(w, r, story, data.ResolutionAuthorSuspended) {
+		return
+	}
+
+	err = app.StoryModel.Hide(story.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.ServerError(w, err)
+		return
+	}
+
+	before := userSnapshot(author)
+	err = app.UserModel.SetDisabled(author, true)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.SessionModel.DeleteAllForUser(author.ID, "")
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditModSuspend,
+		TargetType: "user",
+		TargetID:   author.ID,
+		Before:     before,
+		After:      userSnapshot(author),
+		Metadata:   map[string]interface{}{"email": author.Email, "story_id": story.ID},
+	})
+
+	app.flashRedirect(w, r, author.Email+" has been suspended and the story hidden.", "/moderation")
+}
+
+// resolveReports closes the open reports against a story. If another moderator
+// got there first it tells the user so and returns false, as it does on errors.
+func (app *Application) resolveReports(w http.ResponseWriter, r *http.Request, story *data.Story, resolution string) bool {
+	err := app.ReportModel.Resolve(story.ID, app.ContextGetUser(r).ID, resolution)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.flashRedirect(w, r, "The reports against this story have already been resolved.", "/moderation")
+		} else {
+			app.ServerError(w, err)
+		}
+		return false
+	}
+	return true
+}
+
+// storyFromQuery loads the story named by the ?id= parameter. It writes the
+// error response and returns false on failure.
+func (app *Application) storyFromQuery(w http.ResponseWriter, r *http.Request) (*data.Story, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		app.ClientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	story, err := app.StoryModel.Get(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return nil, false
+	}
+	return story, true
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// MyStoriesHandler lists the current user's stories, one status at a time, so
+// drafts are kept apart from published work.
+func (app *Application) MyStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+	filters := readFilters(r, "")
+
+	status := r.URL.Query().Get("status")
+	if !data.ValidStoryStatus(status) {
+		status = data.StatusPublished
+	}
+
+	counts, err := app.StoryModel.CountByStatusForUser(user.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	stories, metadata, err := app.StoryModel.ListForUser(user.ID, status, filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "my_stories.tmpl", map[string]interface{}{
+		"Stories":  stories,
+		"Metadata": metadata,
+		"Status":   status,
+		"Statuses": data.StoryStatuses,
+		"Counts":   counts,
+		"BaseURL":  app.BaseURL,
+	})
+}
+
+// storyReturnTo is where to send the author after saving a story: the public
+// listing for published stories, otherwise the matching tab of My Stories.
+func storyReturnTo(story *data.Story) string {
+	if story.Status == data.StatusPublished {
+		return "/stories"
+	}
+	return "/stories/mine?status=" + story.Status
+}
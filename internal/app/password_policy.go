@@ -0,0 +1,128 @@
+package app
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/RudyItza/ahsehdis/internal/breached"
+)
+
+// PasswordPolicy decides whether a new password is acceptable. It is applied
+// at signup, on password change and on password reset.
+type PasswordPolicy struct {
+	MinLength   int
+	MinStrength int             // minimum score from passwordStrength, 0 (very weak) to 4 (strong)
+	Breached    breached.Corpus // passwords known from breaches; nil skips the check
+}
+
+// NewPasswordPolicy returns the default policy checking against corpus, which may be nil.
+func NewPasswordPolicy(corpus breached.Corpus) *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength:   8,
+		MinStrength: 2,
+		Breached:    corpus,
+	}
+}
+
+// Validate records a "password" field error on v if the password is
+// unacceptable for the account with the given email. The error return is
+// only for failures reading the breached password corpus.
+func (p *PasswordPolicy) Validate(v *Validator, password, email string) error {
+	v.Check(NotBlank(password), "password", "Password is required")
+	v.Check(len(password) >= p.MinLength, "password", "Password must be at least "+strconv.Itoa(p.MinLength)+" characters")
+
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	if len(localPart) >= 3 {
+		v.Check(!strings.Contains(strings.ToLower(password), localPart), "password", "Password must not contain your email address")
+	}
+
+	v.Check(passwordStrength(password) >= p.MinStrength, "password",
+		"Password is too easy to guess. Try a longer phrase or mix in numbers and symbols")
+
+	// Only consult the corpus once the cheap checks pass
+	if _, failed := v.Errors["password"]; failed || p.Breached == nil {
+		return nil
+	}
+
+	found, err := p.Breached.Contains(password)
+	if err != nil {
+		return err
+	}
+	v.Check(!found, "password", "This password has appeared in a data breach. Please choose a different one")
+	return nil
+}
+
+// commonFragments are substrings that add next to nothing to a password.
+var commonFragments = []string{
+	"password", "passw0rd", "qwerty", "azerty", "asdf", "zxcv", "letmein",
+	"welcome", "admin", "login", "iloveyou", "monkey", "dragon", "abc123",
+}
+
+// passwordStrength estimates how hard a password is to guess on a 0-4 scale.
+// It approximates entropy from the character classes used and the password's
+// length, discounting repeated characters, runs like "abcd" or "4321" and
+// well-known fragments.
+func passwordStrength(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {other, 33}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	// Characters that repeat or continue a sequence are nearly free to guess
+	effective := 0.0
+	runes := []rune(password)
+	for i, r := range runes {
+		if i > 0 {
+			if step := r - runes[i-1]; step >= -1 && step <= 1 {
+				effective += 0.25
+				continue
+			}
+		}
+		effective++
+	}
+
+	lowered := strings.ToLower(password)
+	for _, fragment := range commonFragments {
+		if strings.Contains(lowered, fragment) {
+			effective -= float64(len(fragment)) - 1
+		}
+	}
+
+	bits := effective * math.Log2(float64(pool))
+	switch {
+	case bits < 28:
+		return 0
+	case bits < 36:
+		return 1
+	case bits < 60:
+		return 2
+	case bits < 80:
+		return 3
+	default:
+		return 4
+	}
+}
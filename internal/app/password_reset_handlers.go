@@ -0,0 +1,167 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// passwordResetTTL is how long a password reset link stays valid.
+const passwordResetTTL = time.Hour
+
+// ForgotPasswordForm displays the form to request a password reset link.
+func (app *Application) ForgotPasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.Render(w, r, "forgot_password.tmpl", nil)
+}
+
+// ForgotPasswordHandler emails a reset link if the address belongs to an account.
+// The response is identical either way so the form cannot be used to probe for accounts.
+func (app *Application) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	email := r.PostForm.Get("email")
+
+	v := NewValidator()
+	v.Check(NotBlank(email), "email", "Email is required")
+	v.Check(ValidateEmail(email), "email", "Invalid email format")
+
+	if !v.Valid() {
+		app.Render(w, r, "forgot_password.tmpl", map[string]interface{}{
+			"Errors": v.Errors,
+			"Email":  email,
+		})
+		return
+	}
+
+	user, err := app.UserModel.GetByEmail(email)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.ServerError(w, err)
+		return
+	}
+
+	if user != nil {
+		token, err := app.OneTimeTokenModel.New(user.ID, data.PurposePasswordReset, passwordResetTTL)
+		if err != nil {
+			app.ServerError(w, err)
+			return
+		}
+
+		app.SendEmail(user.Email, "password_reset.tmpl", map[string]interface{}{
+			"URL": app.BaseURL + "/password/reset?token=" + url.QueryEscape(token.Plaintext),
+			"TTL": "1 hour",
+		})
+	}
+
+	app.flashRedirect(w, r, "If an account exists for that address, we have emailed a link to reset its password.", "/login")
+}
+
+// ResetPasswordForm displays the form to choose a new password, if the link is still valid.
+func (app *Application) ResetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	_, err := app.OneTimeTokenModel.GetUserForToken(token, data.PurposePasswordReset)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.Render(w, r, "reset_password.tmpl", map[string]interface{}{
+				"InvalidToken": true,
+			})
+			return
+		}
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "reset_password.tmpl", map[string]interface{}{
+		"Token": token,
+	})
+}
+
+// ResetPasswordHandler consumes the reset token, sets the new password and signs out every session.
+func (app *Application) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	password := r.PostForm.Get("password")
+	confirmation := r.PostForm.Get("password_confirmation")
+
+	// Look the user up first so the policy can check the password against their email
+	user, err := app.OneTimeTokenModel.GetUserForToken(token, data.PurposePasswordReset)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.Render(w, r, "reset_password.tmpl", map[string]interface{}{
+				"InvalidToken": true,
+			})
+			return
+		}
+		app.ServerError(w, err)
+		return
+	}
+
+	v := NewValidator()
+	if err := app.PasswordPolicy.Validate(v, password, user.Email); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	v.Check(password == confirmation, "password_confirmation", "Passwords do not match")
+
+	if !v.Valid() {
+		app.Render(w, r, "reset_password.tmpl", map[string]interface{}{
+			"Errors": v.Errors,
+			"Token":  token,
+		})
+		return
+	}
+
+	// Consuming the token is what authorises the change; it fails if the link was used in the meantime
+	userID, err := app.OneTimeTokenModel.Consume(token, data.PurposePasswordReset)
+	if err != nil || userID != user.ID {
+		if err == nil || errors.Is(err, data.ErrRecordNotFound) {
+			app.Render(w, r, "reset_password.tmpl", map[string]interface{}{
+				"InvalidToken": true,
+			})
+			return
+		}
+		app.ServerError(w, err)
+		return
+	}
+
+	err = user.SetPassword(password)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.UserModel.UpdatePassword(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// Invalidate any other outstanding reset links and every existing session
+	err = app.OneTimeTokenModel.DeleteAllForUser(user.ID, data.PurposePasswordReset)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.SessionModel.DeleteAllForUser(user.ID, "")
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditPasswordReset, Actor: user, TargetType: "user", TargetID: user.ID})
+
+	app.flashRedirect(w, r, "Your password has been reset. Please log in.", "/login")
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// Action is something a user may try to do to a resource.
+type Action string
+
+// Actions checked by Can.
+const (
+	ActionViewStory   Action = "story:view"
+	ActionEditStory   Action = "story:edit"
+	ActionDeleteStory Action = "story:delete"
+	ActionReportStory Action = "story:report"
+	ActionSuspendUser Action = "user:suspend"
+
+	ActionCommentStory  Action = "story:comment"
+	ActionLockComments  Action = "story:lock_comments"
+	ActionEditComment   Action = "comment:edit"
+	ActionDeleteComment Action = "comment:delete"
+)
+
+// Can reports whether user may perform action on resource. It is the single
+// place ownership and staff permissions are decided; handlers and templates
+// ask it rather than comparing user IDs themselves. A nil user can only view
+// public stories.
+func Can(user *data.User, action Action, resource interface{}) bool {
+	if user == nil {
+		story, ok := resource.(*data.Story)
+		return ok && action == ActionViewStory && story.IsPublic()
+	}
+
+	switch resource := resource.(type) {
+	case *data.Story:
+		switch action {
+		case ActionViewStory:
+			if resource.IsPublic() || resource.UserID == user.ID {
+				return true
+			}
+			// Moderators review hidden stories, but drafts and private stories stay with their author
+			return resource.IsShared() && user.HasRole(data.RoleModerator)
+		case ActionEditStory, ActionDeleteStory:
+			// Authors manage their own stories; moderators can step in on shared ones,
+			// but drafts and private stories stay with their author
+			return resource.UserID == user.ID || (resource.IsShared() && user.HasRole(data.RoleModerator))
+		case ActionReportStory:
+			return resource.UserID != user.ID
+		case ActionCommentStory:
+			// Anyone who can read a shared story may discuss it until it is locked
+			return resource.IsPublic() && !resource.CommentsLocked()
+		case ActionLockComments:
+			return resource.UserID == user.ID || user.HasRole(data.RoleModerator)
+		}
+	case *data.Comment:
+		if resource.IsDeleted() {
+			return false
+		}
+		switch action {
+		case ActionEditComment:
+			return resource.UserID == user.ID
+		case ActionDeleteComment:
+			// Moderators can remove anyone's comment
+			return resource.UserID == user.ID || user.HasRole(data.RoleModerator)
+		}
+	case *data.User:
+		switch action {
+		case ActionSuspendUser:
+			// Moderators can only suspend accounts below their own role, and never their own
+			return user.HasRole(data.RoleModerator) && resource.ID != user.ID && !resource.Role.AtLeast(user.Role)
+		}
+	}
+
+	return false
+}
+
+// RequireRole blocks users whose role is below role. It must run after
+// RequireAuthentication or RequireAPIAuthentication.
+func (app *Application) RequireRole(role data.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.ContextGetUser(r).HasRole(role) {
+			if isAPIRequest(r) {
+				app.APIClientError(w, http.StatusForbidden)
+				return
+			}
+			app.ClientError(w, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
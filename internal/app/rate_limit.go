@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/ratelimit"
+)
+
+// Rate limit policies applied by Routes. Each policy has its own buckets, so a
+// request passing through several policies is counted once by each.
+const (
+	RateLimitGlobal  = "global"  // every request
+	RateLimitAuth    = "auth"    // signup, login and account recovery forms
+	RateLimitWrite   = "write"   // requests that change stories
+	RateLimitPublish = "publish" // creating a new story
+)
+
+// DefaultRateLimits returns the rate for each policy used when none is configured.
+func DefaultRateLimits() map[string]ratelimit.Rate {
+	return map[string]ratelimit.Rate{
+		RateLimitGlobal:  ratelimit.PerMinute(300),
+		RateLimitAuth:    ratelimit.PerMinute(20),
+		RateLimitWrite:   ratelimit.PerMinute(30),
+		RateLimitPublish: {Burst: 5, Interval: 2 * time.Minute},
+	}
+}
+
+// ParseRateLimits overlays a list like "global=600/m,publish=10/h" on the defaults.
+func ParseRateLimits(s string) (map[string]ratelimit.Rate, error) {
+	limits := DefaultRateLimits()
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		if _, known := limits[name]; !ok || !known {
+			return nil, fmt.Errorf("invalid rate limit %q: want policy=N/unit with a known policy", entry)
+		}
+
+		rate, err := ratelimit.ParseRate(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[name] = rate
+	}
+	return limits, nil
+}
+
+// RateLimit limits requests under the named policy. Signed-in users are
+// counted per account and everyone else per client IP. Each response carries
+// RateLimit-* headers; requests over the limit get a 429 with Retry-After.
+// Policies without a configured rate, or a nil Limiter, let everything through.
+func (app *Application) RateLimit(policy string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rate, ok := app.RateLimits[policy]
+		if app.Limiter == nil || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := app.Limiter.Allow(app.rateLimitKey(policy, r), rate)
+		if err != nil {
+			// Fail open: an unavailable bucket store should not take the site down with it
+			app.ErrorLog.Output(2, err.Error())
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		window := int(rate.Interval * time.Duration(rate.Burst) / time.Second)
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rate.Burst, window))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			app.rateLimitExceededResponse(w, r, result.RetryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey identifies the caller's bucket for a policy.
+func (app *Application) rateLimitKey(policy string, r *http.Request) string {
+	if user := app.ContextGetUser(r); user != nil {
+		return fmt.Sprintf("rl:%s:user:%d", policy, user.ID)
+	}
+	return fmt.Sprintf("rl:%s:ip:%s", policy, app.ClientIP(r))
+}
+
+// rateLimitExceededResponse sends a 429, as JSON for the API and as a page otherwise.
+func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if isAPIRequest(r) {
+		app.APIErrorResponse(w, http.StatusTooManyRequests, "rate limit exceeded, retry in "+formatWait(retryAfter))
+		return
+	}
+
+	app.RenderStatus(w, r, http.StatusTooManyRequests, "too_many_requests.tmpl", map[string]interface{}{
+		"Wait": formatWait(retryAfter),
+	})
+}
+
+// ceilSeconds rounds a duration up to whole seconds, with a minimum of one.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
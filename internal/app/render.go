@@ -1,20 +1,38 @@
 package app
 
 import (
+	"bytes"
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gorilla/csrf"
 )
 
+// datetimeLocalLayout is the format of an HTML datetime-local input's value.
+const datetimeLocalLayout = "2006-01-02T15:04"
+
 // Define a map of custom template functions that can be used in templates.
 var templateFunctions = template.FuncMap{
 	// Format a time.Time value into a human-readable string.
 	"humanDate": func(t time.Time) string {
 		return t.Format("02 Jan 2006 at 15:04")
 	},
+	// Format an optional time for a datetime-local input, in the server's time zone.
+	"datetimeLocal": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(time.Local).Format(datetimeLocalLayout)
+	},
+	// Name the server's time zone, in which datetime-local inputs are read.
+	"serverZone": func() string {
+		return time.Now().Format("MST")
+	},
+	// Join a list of strings with a separator.
+	"join": strings.Join,
 	// Truncate a string to a maximum length, adding "..." if it exceeds.
 	"truncate": func(s string, maxLength int) string {
 		if len(s) <= maxLength {
@@ -30,10 +48,19 @@ var templateFunctions = template.FuncMap{
 	"subtract": func(a, b int) int {
 		return a - b
 	},
+	// Ask the policy layer whether a user may perform an action on a resource.
+	"can": Can,
 }
 
 // Render renders an HTML template and writes it to the response writer.
 func (app *Application) Render(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	app.RenderStatus(w, r, http.StatusOK, name, data)
+}
+
+// RenderStatus renders an HTML template and writes it to the response writer
+// with the given status code. The page is rendered in full before anything is
+// written, so a template error can still be reported as a 500.
+func (app *Application) RenderStatus(w http.ResponseWriter, r *http.Request, status int, name string, data map[string]interface{}) {
 	// If no data map is provided, initialize an empty one.
 	if data == nil {
 		data = make(map[string]interface{})
@@ -43,7 +70,11 @@ func (app *Application) Render(w http.ResponseWriter, r *http.Request, name stri
 	data[csrf.TemplateTag] = csrf.TemplateField(r)
 	// Indicate whether a user is currently authenticated.
 
-	data["IsAuthenticated"] = app.ContextGetUser(r) != nil
+	user := app.ContextGetUser(r)
+	data["IsAuthenticated"] = user != nil
+	data["CurrentUser"] = user
+	// Remind signed-in users who have not confirmed their email address yet.
+	data["NeedsVerification"] = user != nil && !user.IsVerified()
 	// If there are flash messages in the request context, add them to the data.
 	if flashes, ok := r.Context().Value("flashes").([]interface{}); ok {
 		data["Flashes"] = flashes
@@ -61,10 +92,15 @@ func (app *Application) Render(w http.ResponseWriter, r *http.Request, name stri
 		return
 	}
 
-	// Execute the base layout template with the provided data.// Execute the base layout template with the provided data.
-	err = ts.ExecuteTemplate(w, "base", data)
+	// Execute the base layout template with the provided data.
+	buf := new(bytes.Buffer)
+	err = ts.ExecuteTemplate(buf, "base", data)
 	// If template execution fails, log the error and send a 500 response.
 	if err != nil {
 		app.ServerError(w, err)
+		return
 	}
+
+	w.WriteHeader(status)
+	buf.WriteTo(w)
 }
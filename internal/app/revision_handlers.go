@@ -0,0 +1,127 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/RudyItza/ahsehdis/internal/worddiff"
+)
+
+// StoryHistoryHandler lists a story's revisions and shows a word-level diff
+// between two of them, by default the latest and the one before it.
+func (app *Application) StoryHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if !Can(app.ContextGetUser(r), ActionEditStory, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	revisions, err := app.StoryModel.Revisions(story.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.ServerError(w, errors.New("story has no revisions"))
+		return
+	}
+
+	// Revisions are newest first; index them by number for the comparison
+	byNumber := make(map[int]*data.StoryRevision, len(revisions))
+	for _, rev := range revisions {
+		byNumber[rev.Revision] = rev
+	}
+
+	to, ok := byNumber[queryInt(r, "to")]
+	if !ok {
+		to = revisions[0]
+	}
+	from, ok := byNumber[queryInt(r, "from")]
+	if !ok {
+		from = byNumber[to.Revision-1]
+		if from == nil {
+			from = to
+		}
+	}
+
+	app.Render(w, r, "story_history.tmpl", map[string]interface{}{
+		"Story":       story,
+		"Revisions":   revisions,
+		"Latest":      revisions[0].Revision,
+		"From":        from,
+		"To":          to,
+		"TitleDiff":   worddiff.Diff(from.Title, to.Title),
+		"ContentDiff": worddiff.Diff(from.Content, to.Content),
+	})
+}
+
+// StoryRestoreRevisionHandler rolls a story back to an earlier revision,
+// saving the result as a new revision.
+func (app *Application) StoryRestoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	story, ok := app.storyFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	if !Can(user, ActionEditStory, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	rev, err := app.StoryModel.GetRevision(story.ID, queryInt(r, "revision"))
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	historyURL := "/story/history?id=" + strconv.Itoa(story.ID)
+	if rev.Title == story.Title && rev.Content == story.Content {
+		app.flashRedirect(w, r, "Revision "+strconv.Itoa(rev.Revision)+" is the same as the current version.", historyURL)
+		return
+	}
+
+	before := storySnapshot(story)
+	err = app.StoryModel.RestoreRevision(story, rev, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.flashRedirect(w, r, "The story was changed while you were restoring it. Check the history and try again.", historyURL)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.ClientError(w, http.StatusNotFound)
+		default:
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{
+		Action:     data.AuditStoryUpdate,
+		TargetType: "story",
+		TargetID:   story.ID,
+		Before:     before,
+		After:      storySnapshot(story),
+		Metadata:   map[string]interface{}{"restored_from": rev.Revision},
+	})
+
+	app.flashRedirect(w, r, "Restored revision "+strconv.Itoa(rev.Revision)+" as a new revision.", historyURL)
+}
+
+// queryInt reads a positive integer query parameter, returning 0 if it is missing or invalid.
+func queryInt(r *http.Request, key string) int {
+	n, err := strconv.Atoi(r.URL.Query().Get(key))
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
@@ -1,6 +1,10 @@
 package app
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
 
 // Routes defines the main application routes and middleware stack.
 func (app *Application) Routes() http.Handler {
@@ -15,18 +19,90 @@ func (app *Application) Routes() http.Handler {
 	// Public routes (Accessible without login)
 	mux.HandleFunc("/", app.HomeHandler)
 	mux.HandleFunc("/login", app.LoginForm)
-	mux.HandleFunc("/login/submit", app.LoginHandler)
+	mux.Handle("/login/submit", app.RateLimit(RateLimitAuth, http.HandlerFunc(app.LoginHandler)))
+	mux.HandleFunc("/login/2fa", app.LoginTwoFactorForm)
+	mux.Handle("/login/2fa/submit", app.RateLimit(RateLimitAuth, http.HandlerFunc(app.LoginTwoFactorHandler)))
 	mux.HandleFunc("/signup", app.SignupForm)
-	mux.HandleFunc("/signup/submit", app.SignupHandler)
+	mux.Handle("/signup/submit", app.RateLimit(RateLimitAuth, http.HandlerFunc(app.SignupHandler)))
+	mux.HandleFunc("/password/forgot", app.ForgotPasswordForm)
+	mux.Handle("/password/forgot/submit", app.RateLimit(RateLimitAuth, http.HandlerFunc(app.ForgotPasswordHandler)))
+	mux.HandleFunc("/password/reset", app.ResetPasswordForm)
+	mux.Handle("/password/reset/submit", app.RateLimit(RateLimitAuth, http.HandlerFunc(app.ResetPasswordHandler)))
+	mux.HandleFunc("/verify-email", app.VerifyEmailHandler)
+	mux.HandleFunc("/story/{id}/{slug}", app.ShowStoryHandler)
+	mux.HandleFunc("/story/{id}", app.ShowStoryHandler)
+	mux.HandleFunc("/story/view", app.LegacyShowStoryHandler)
+	mux.HandleFunc("/tags", app.TagsHandler)
+	mux.HandleFunc("/tags/{name}", app.TagStoriesHandler)
+	mux.HandleFunc("/search", app.SearchHandler)
 
 	// Protected Routes (Require user authentication)
 	mux.Handle("/stories", app.RequireAuthentication(http.HandlerFunc(app.ViewStoriesHandler)))
-	mux.Handle("/story/submit", app.RequireAuthentication(http.HandlerFunc(app.SubmitStoryForm)))
-	mux.Handle("/story/create", app.RequireAuthentication(http.HandlerFunc(app.SubmitStoryHandler)))
+	mux.Handle("/stories/mine", app.RequireAuthentication(http.HandlerFunc(app.MyStoriesHandler)))
+	mux.Handle("/story/submit", app.RequireAuthentication(app.publishing(http.HandlerFunc(app.SubmitStoryForm))))
+	mux.Handle("/story/create", app.RequireAuthentication(app.RateLimit(RateLimitPublish, app.publishing(http.HandlerFunc(app.SubmitStoryHandler)))))
 	mux.Handle("/story/edit", app.RequireAuthentication(http.HandlerFunc(app.EditStoryForm)))
-	mux.Handle("/story/update", app.RequireAuthentication(http.HandlerFunc(app.EditStoryHandler)))
-	mux.Handle("/story/delete", app.RequireAuthentication(http.HandlerFunc(app.DeleteStoryHandler)))
+	mux.Handle("/story/update", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.EditStoryHandler))))
+	mux.Handle("POST /story/delete", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.DeleteStoryHandler))))
+	mux.Handle("/story/history", app.RequireAuthentication(http.HandlerFunc(app.StoryHistoryHandler)))
+	mux.Handle("POST /story/history/restore", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.StoryRestoreRevisionHandler))))
+	mux.Handle("/trash", app.RequireAuthentication(http.HandlerFunc(app.TrashHandler)))
+	mux.Handle("POST /trash/restore", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.TrashRestoreHandler))))
+	mux.Handle("POST /trash/purge", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.TrashPurgeHandler))))
+	mux.Handle("/story/report", app.RequireAuthentication(http.HandlerFunc(app.ReportStoryForm)))
+	mux.Handle("POST /story/report/submit", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.ReportStoryHandler))))
+	mux.Handle("POST /story/comment", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.CreateCommentHandler))))
+	mux.Handle("POST /story/comments/lock", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.LockCommentsHandler))))
+	mux.Handle("POST /story/comments/unlock", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.UnlockCommentsHandler))))
+	mux.Handle("/comment/edit", app.RequireAuthentication(http.HandlerFunc(app.EditCommentForm)))
+	mux.Handle("POST /comment/update", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.UpdateCommentHandler))))
+	mux.Handle("POST /comment/delete", app.RequireAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.DeleteCommentHandler))))
 	mux.Handle("/logout", app.RequireAuthentication(http.HandlerFunc(app.LogoutHandler)))
+	mux.Handle("/tokens", app.RequireAuthentication(http.HandlerFunc(app.TokensHandler)))
+	mux.Handle("POST /tokens/create", app.RequireAuthentication(http.HandlerFunc(app.CreateTokenHandler)))
+	mux.Handle("POST /tokens/revoke", app.RequireAuthentication(http.HandlerFunc(app.RevokeTokenHandler)))
+	mux.Handle("/account/sessions", app.RequireAuthentication(http.HandlerFunc(app.SessionsHandler)))
+	mux.Handle("POST /account/sessions/revoke", app.RequireAuthentication(http.HandlerFunc(app.RevokeSessionHandler)))
+	mux.Handle("POST /account/sessions/revoke-all", app.RequireAuthentication(http.HandlerFunc(app.RevokeOtherSessionsHandler)))
+	mux.Handle("/account/password", app.RequireAuthentication(http.HandlerFunc(app.ChangePasswordForm)))
+	mux.Handle("/account/password/update", app.RequireAuthentication(http.HandlerFunc(app.ChangePasswordHandler)))
+	mux.Handle("/account/verify", app.RequireAuthentication(http.HandlerFunc(app.VerifyEmailNotice)))
+	mux.Handle("/account/verify/resend", app.RequireAuthentication(app.RateLimit(RateLimitAuth, http.HandlerFunc(app.ResendVerificationHandler))))
+	mux.Handle("/account/2fa", app.RequireAuthentication(http.HandlerFunc(app.TwoFactorHandler)))
+	mux.Handle("/account/2fa/qr.png", app.RequireAuthentication(http.HandlerFunc(app.TwoFactorQRHandler)))
+	mux.Handle("/account/2fa/enable", app.RequireAuthentication(http.HandlerFunc(app.EnableTwoFactorHandler)))
+	mux.Handle("/account/2fa/disable", app.RequireAuthentication(http.HandlerFunc(app.DisableTwoFactorHandler)))
+	mux.Handle("/account/2fa/recovery-codes", app.RequireAuthentication(http.HandlerFunc(app.RegenerateRecoveryCodesHandler)))
+
+	// Moderation queue (moderators and admins)
+	mux.Handle("/moderation", app.moderator(app.ModerationQueueHandler))
+	mux.Handle("POST /moderation/dismiss", app.moderator(app.ModerationDismissHandler))
+	mux.Handle("POST /moderation/hide", app.moderator(app.ModerationHideHandler))
+	mux.Handle("POST /moderation/suspend", app.moderator(app.ModerationSuspendHandler))
+
+	// Admin area (admins only)
+	mux.Handle("/admin", app.admin(app.AdminHandler))
+	mux.Handle("/admin/users", app.admin(app.AdminUsersHandler))
+	mux.Handle("POST /admin/users/role", app.admin(app.AdminSetRoleHandler))
+	mux.Handle("POST /admin/users/disable", app.admin(app.AdminDisableUserHandler))
+	mux.Handle("POST /admin/users/enable", app.admin(app.AdminEnableUserHandler))
+	mux.Handle("POST /admin/users/force-reset", app.admin(app.AdminForcePasswordResetHandler))
+	mux.Handle("/admin/stories", app.admin(app.AdminStoriesHandler))
+	mux.Handle("POST /admin/stories/delete", app.admin(app.AdminSoftDeleteStoryHandler))
+	mux.Handle("POST /admin/stories/restore", app.admin(app.AdminRestoreStoryHandler))
+	mux.Handle("POST /admin/stories/purge", app.admin(app.AdminPurgeStoryHandler))
+	mux.Handle("/admin/audit", app.admin(app.AdminAuditHandler))
+	mux.Handle("/admin/audit/export", app.admin(app.AdminAuditExportHandler))
+
+	// JSON API (v1). Reads are public, writes require an authenticated user
+	// (session cookie or bearer token).
+	mux.HandleFunc("/api/", app.APINotFoundHandler)
+	mux.HandleFunc("GET /api/v1/stories", app.APIListStoriesHandler)
+	mux.HandleFunc("GET /api/v1/stories/{id}", app.APIGetStoryHandler)
+	mux.HandleFunc("GET /api/v1/search", app.APISearchHandler)
+	mux.Handle("POST /api/v1/stories", app.RequireAPIAuthentication(app.RateLimit(RateLimitPublish, app.publishing(http.HandlerFunc(app.APICreateStoryHandler)))))
+	mux.Handle("PUT /api/v1/stories/{id}", app.RequireAPIAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.APIUpdateStoryHandler))))
+	mux.Handle("DELETE /api/v1/stories/{id}", app.RequireAPIAuthentication(app.RateLimit(RateLimitWrite, http.HandlerFunc(app.APIDeleteStoryHandler))))
 
 	// -------- Middleware Stack --------
 	// Wrap the entire mux with a chain of middleware for:
@@ -36,12 +112,15 @@ func (app *Application) Routes() http.Handler {
 	// - HTTPS enforcement
 	// - Flash message support
 	// - User authentication context loading
+	// - Global rate limiting (after authentication so users are counted per account)
 	return app.RecoverPanic(
 		app.SecureHeaders(
 			app.LogRequest(
 				app.EnforceHTTPS(
 					app.FlashMessages(
-						app.Authenticate(mux),
+						app.Authenticate(
+							app.RateLimit(RateLimitGlobal, mux),
+						),
 					),
 				),
 			),
@@ -49,6 +128,25 @@ func (app *Application) Routes() http.Handler {
 	)
 }
 
+// publishing guards routes that publish stories. When the verified-email policy is
+// enabled, unverified users are turned away; otherwise it is a no-op.
+func (app *Application) publishing(next http.Handler) http.Handler {
+	if app.RequireVerifiedToPublish {
+		return app.RequireVerifiedEmail(next)
+	}
+	return next
+}
+
+// moderator guards a handler so only signed-in moderators and admins can reach it.
+func (app *Application) moderator(handler http.HandlerFunc) http.Handler {
+	return app.RequireAuthentication(app.RequireRole(data.RoleModerator, handler))
+}
+
+// admin guards a handler so only signed-in admins can reach it.
+func (app *Application) admin(handler http.HandlerFunc) http.Handler {
+	return app.RequireAuthentication(app.RequireRole(data.RoleAdmin, handler))
+}
+
 // cacheControl is a middleware that sets a long-term cache policy for static assets.
 func (app *Application) cacheControl(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
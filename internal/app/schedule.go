@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// readScheduledAt reads the publication time from a story form. A datetime-local
+// input carries no time zone, so the time is taken to be in the server's. A
+// malformed value is reported on v and read as no time at all.
+func readScheduledAt(r *http.Request, v *Validator) *time.Time {
+	value := r.PostForm.Get("scheduled_at")
+	if value == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation(datetimeLocalLayout, value, time.Local)
+	if err != nil {
+		v.AddError("scheduled_at", "Publish time must be a date and time")
+		return nil
+	}
+	return &t
+}
+
+// StoryPublished is the scheduler's notification hook. It logs each story
+// that went out on schedule and lets its author know by email.
+func (app *Application) StoryPublished(story *data.Story) {
+	app.InfoLog.Printf("published scheduled story %d", story.ID)
+
+	app.SendEmail(story.UserEmail, "story_published.tmpl", map[string]interface{}{
+		"Title": story.Title,
+		"URL":   app.BaseURL + story.Path(),
+	})
+}
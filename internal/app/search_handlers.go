@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// searchHelp explains the search syntax on the search page and in API errors.
+const searchHelp = `Use "quotes" for a phrase, a trailing * to match the start of a word, - to leave a word out and OR for either word.`
+
+// searchModeFuzzy is the mode parameter value that asks for a fuzzy search.
+const searchModeFuzzy = "fuzzy"
+
+// readSearchQuery reads the q and mode parameters of a search request.
+func (app *Application) readSearchQuery(r *http.Request) data.SearchQuery {
+	qs := r.URL.Query()
+	return data.SearchQuery{
+		Text:     strings.TrimSpace(qs.Get("q")),
+		Fuzzy:    qs.Get("mode") == searchModeFuzzy,
+		Variants: app.Spelling,
+	}
+}
+
+// suggestSpelling returns a "did you mean" search for a full-text query, or ""
+// when there is none. Suggestions are a nicety, so failures are only logged.
+func (app *Application) suggestSpelling(query data.SearchQuery) string {
+	if query.Fuzzy {
+		return ""
+	}
+	suggestion, err := app.StoryModel.SuggestSpelling(query.Text, app.Spelling)
+	if err != nil {
+		app.ErrorLog.Output(2, err.Error())
+		return ""
+	}
+	return suggestion
+}
+
+// SearchHandler searches published stories, showing each result with the
+// matching words highlighted and suggesting other spellings for unknown words.
+func (app *Application) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := app.readSearchQuery(r)
+	templateData := map[string]interface{}{
+		"Query": query.Text,
+		"Fuzzy": query.Fuzzy,
+		"Help":  searchHelp,
+	}
+	if query.Text == "" {
+		app.Render(w, r, "search.tmpl", templateData)
+		return
+	}
+
+	results, metadata, err := app.StoryModel.Search(query, readFilters(r, ""))
+	if err != nil {
+		if !errors.Is(err, data.ErrBlankSearch) {
+			app.ServerError(w, err)
+			return
+		}
+		templateData["Error"] = "Enter at least one word to search for."
+	}
+
+	templateData["Results"] = results
+	templateData["Metadata"] = metadata
+	templateData["Suggestion"] = app.suggestSpelling(query)
+	app.Render(w, r, "search.tmpl", templateData)
+}
+
+// APISearchHandler searches published stories and returns the results as JSON,
+// with highlighting given as fragments of text and any spelling suggestion.
+func (app *Application) APISearchHandler(w http.ResponseWriter, r *http.Request) {
+	query := app.readSearchQuery(r)
+
+	results, metadata, err := app.StoryModel.Search(query, readAPIFilters(r))
+	if err != nil {
+		if errors.Is(err, data.ErrBlankSearch) {
+			app.APIFailedValidation(w, map[string]string{"q": "must contain at least one word. " + searchHelp})
+		} else {
+			app.APIServerError(w, err)
+		}
+		return
+	}
+	if results == nil {
+		results = []*data.SearchResult{}
+	}
+
+	envelope := Envelope{"results": results, "metadata": metadata}
+	if suggestion := app.suggestSpelling(query); suggestion != "" {
+		envelope["suggestion"] = suggestion
+	}
+
+	err = app.WriteJSON(w, http.StatusOK, envelope, nil)
+	if err != nil {
+		app.APIServerError(w, err)
+	}
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// PGStore is a sessions.Store that keeps session values in Postgres. The
+// cookie only carries a signed random token, so sessions can be listed per
+// user and revoked server-side before the cookie itself expires.
+type PGStore struct {
+	Codecs   []securecookie.Codec
+	Options  *sessions.Options
+	Sessions *data.SessionModel
+	// IdleTimeout ends sessions that have not been used for this long (0 disables it).
+	IdleTimeout time.Duration
+	// ClientIP extracts the address recorded against each session.
+	ClientIP func(r *http.Request) string
+}
+
+// NewPGStore creates a store backed by the sessions table. keyPairs are used
+// exactly as in sessions.NewCookieStore to sign (and optionally encrypt) the
+// cookie and the stored values.
+func NewPGStore(model *data.SessionModel, keyPairs ...[]byte) *PGStore {
+	store := &PGStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		Sessions: model,
+		ClientIP: remoteIP,
+	}
+	store.MaxAge(store.Options.MaxAge)
+	return store
+}
+
+// MaxAge sets the lifetime of new sessions and of the signed values they carry.
+func (s *PGStore) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+			// Values live in the database, so the 4KB cookie limit does not apply
+			sc.MaxLength(0)
+		}
+	}
+}
+
+// Get returns the named session from the per-request registry, loading it on first use.
+func (s *PGStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session referenced by the request cookie, or returns a fresh
+// session when there is no cookie or the stored session is gone, expired or idle.
+func (s *PGStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.Codecs...); err != nil {
+		// A tampered or outdated cookie simply starts a new session
+		return session, nil
+	}
+
+	row, err := s.Sessions.GetByToken(token)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			return session, nil
+		}
+		return session, err
+	}
+
+	if s.IdleTimeout > 0 && time.Since(row.LastSeenAt) > s.IdleTimeout {
+		return session, s.Sessions.DeleteByToken(token)
+	}
+
+	if err := securecookie.DecodeMulti(name, row.Data, &session.Values, s.Codecs...); err != nil {
+		return session, nil
+	}
+
+	session.ID = token
+	session.IsNew = false
+
+	// Record activity at most once a minute to avoid a write on every request
+	if time.Since(row.LastSeenAt) > time.Minute {
+		if err := s.Sessions.Touch(row.ID, s.ClientIP(r)); err != nil {
+			return session, err
+		}
+	}
+
+	return session, nil
+}
+
+// Save persists the session values and writes the cookie. A negative MaxAge
+// deletes the session from the database and clears the cookie.
+func (s *PGStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Sessions.DeleteByToken(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	row := &data.Session{
+		UserAgent: r.UserAgent(),
+		IP:        s.ClientIP(r),
+		ExpiresAt: time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+
+	if session.ID != "" {
+		err := s.save(session, row, false)
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			if err != nil {
+				return err
+			}
+			return s.writeCookie(w, session)
+		}
+		// The session was revoked while this request was in flight: never
+		// resurrect it with its login, start over as a fresh session instead.
+		delete(session.Values, SessionUserKey)
+	}
+
+	token, err := generateSessionToken()
+	if err != nil {
+		return err
+	}
+	session.ID = token
+
+	if err := s.save(session, row, true); err != nil {
+		return err
+	}
+	return s.writeCookie(w, session)
+}
+
+// Renew moves the session to a new token and deletes the old one. Call it
+// whenever the privilege level changes (e.g. on login) to prevent fixation.
+func (s *PGStore) Renew(session *sessions.Session) error {
+	if session.ID != "" {
+		if err := s.Sessions.DeleteByToken(session.ID); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	session.IsNew = true
+	return nil
+}
+
+// save encodes the session values into row and inserts or updates it.
+func (s *PGStore) save(session *sessions.Session, row *data.Session, insert bool) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	row.Data = encoded
+
+	if userID, ok := session.Values[SessionUserKey].(int); ok {
+		row.UserID = &userID
+	} else {
+		row.UserID = nil
+	}
+
+	if insert {
+		return s.Sessions.Insert(session.ID, row)
+	}
+	return s.Sessions.Update(session.ID, row)
+}
+
+// writeCookie sets the signed session token cookie.
+func (s *PGStore) writeCookie(w http.ResponseWriter, session *sessions.Session) error {
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// generateSessionToken returns a random 256-bit token encoded as base-32.
+func generateSessionToken() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// remoteIP returns the host part of the request's remote address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
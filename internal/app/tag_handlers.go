@@ -0,0 +1,53 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// tagCloudSize is how many of the most used tags the tag cloud shows.
+const tagCloudSize = 50
+
+// TagsHandler shows the most used tags as a cloud, sized by how many
+// published stories carry each one.
+func (app *Application) TagsHandler(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.TagModel.Cloud(tagCloudSize)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "tags.tmpl", map[string]interface{}{
+		"Tags": tags,
+	})
+}
+
+// TagStoriesHandler lists the published stories with a tag. Tag names that are
+// not in normal form are redirected to the page for the normalised name.
+func (app *Application) TagStoriesHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tag := data.NormalizeTag(name)
+	if tag == "" {
+		app.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if tag != name {
+		http.Redirect(w, r, "/tags/"+url.PathEscape(tag), http.StatusMovedPermanently)
+		return
+	}
+
+	filters := readFilters(r, "")
+	stories, metadata, err := app.StoryModel.ListByTag(tag, filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "tag.tmpl", map[string]interface{}{
+		"Tag":      tag,
+		"Stories":  stories,
+		"Metadata": metadata,
+	})
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// tokenLifetimes maps the expiry options offered on the tokens page to durations (0 = never expires).
+var tokenLifetimes = map[string]time.Duration{
+	"30":  30 * 24 * time.Hour,
+	"90":  90 * 24 * time.Hour,
+	"365": 365 * 24 * time.Hour,
+	"0":   0,
+}
+
+// TokensHandler lists the user's personal API tokens alongside the form to mint a new one.
+func (app *Application) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, nil)
+}
+
+// CreateTokenHandler mints a new personal API token and shows its plaintext once.
+func (app *Application) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	// Tokens can only be managed from a browser session, not with another token
+	if app.ContextGetTokenScope(r) != "" {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	name := r.PostForm.Get("name")
+	scope := r.PostForm.Get("scope")
+	expiry := r.PostForm.Get("expiry")
+
+	v := NewValidator()
+	v.Check(NotBlank(name), "name", "Name is required")
+	v.Check(len(name) <= 50, "name", "Name must be 50 characters or less")
+	v.Check(data.ValidScope(scope), "scope", "Scope must be read or write")
+	ttl, ok := tokenLifetimes[expiry]
+	v.Check(ok, "expiry", "Choose a valid expiry")
+
+	if !v.Valid() {
+		app.renderTokens(w, r, map[string]interface{}{
+			"Errors": v.Errors,
+			"Name":   name,
+		})
+		return
+	}
+
+	token, err := app.TokenModel.New(user.ID, name, scope, ttl)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{
+		Action:     data.AuditTokenCreate,
+		TargetType: "token",
+		TargetID:   token.ID,
+		Metadata:   map[string]interface{}{"name": token.Name, "scope": token.Scope},
+	})
+
+	app.renderTokens(w, r, map[string]interface{}{
+		"NewToken": token,
+	})
+}
+
+// RevokeTokenHandler deletes one of the user's tokens.
+func (app *Application) RevokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	if app.ContextGetTokenScope(r) != "" {
+		app.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	id, err := readIDForm(r)
+	if err != nil {
+		app.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.TokenModel.Delete(id, user.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditTokenRevoke, TargetType: "token", TargetID: id})
+
+	app.flashRedirect(w, r, "Token revoked.", "/tokens")
+}
+
+// renderTokens loads the user's tokens and renders the tokens page with any extra data.
+func (app *Application) renderTokens(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	user := app.ContextGetUser(r)
+
+	tokens, err := app.TokenModel.GetAllForUser(user.ID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["Tokens"] = tokens
+
+	app.Render(w, r, "tokens.tmpl", data)
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// TrashHandler lists the current user's deleted stories.
+func (app *Application) TrashHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+	filters := readFilters(r, "")
+
+	stories, metadata, err := app.StoryModel.ListDeletedForUser(user.ID, filters)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "trash.tmpl", map[string]interface{}{
+		"Stories":   stories,
+		"Metadata":  metadata,
+		"Retention": retentionText(app.TrashRetention),
+	})
+}
+
+// TrashRestoreHandler takes a story back out of the trash.
+func (app *Application) TrashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.trashedStory(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.StoryModel.Restore(story.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditStoryRestore, TargetType: "story", TargetID: story.ID})
+
+	app.flashRedirect(w, r, "Story restored.", "/trash")
+}
+
+// TrashPurgeHandler permanently deletes a story from the trash.
+func (app *Application) TrashPurgeHandler(w http.ResponseWriter, r *http.Request) {
+	story, ok := app.trashedStory(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.StoryModel.Purge(story.ID)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditStoryPurge, TargetType: "story", TargetID: story.ID, Before: storySnapshot(story)})
+
+	app.flashRedirect(w, r, "Story permanently deleted.", "/trash")
+}
+
+// trashedStory loads the deleted story named by the ?id= parameter and checks
+// the current user may manage it. It writes the error response and returns false on failure.
+func (app *Application) trashedStory(w http.ResponseWriter, r *http.Request) (*data.Story, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		app.ClientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+
+	story, err := app.StoryModel.GetDeleted(id)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.ClientError(w, http.StatusNotFound)
+		} else {
+			app.ServerError(w, err)
+		}
+		return nil, false
+	}
+
+	if !Can(app.ContextGetUser(r), ActionDeleteStory, story) {
+		app.ClientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return story, true
+}
+
+// retentionText describes the trash retention period, or returns "" when
+// stories are kept forever.
+func retentionText(retention time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case retention <= 0:
+		return ""
+	case retention == day:
+		return "1 day"
+	case retention%day == 0:
+		return fmt.Sprintf("%d days", retention/day)
+	default:
+		return retention.String()
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpIssuer         = "Meka-tell-yuh"
+	totpPeriod         = 30 // seconds per TOTP time step
+	recoveryCodeCount  = 10
+	twoFactorLoginTTL  = 5 * time.Minute // time allowed between the password and second-factor steps
+	recoveryCodeLength = 10
+)
+
+// totpValidateOpts are the parameters authenticator apps use by default.
+var totpValidateOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+// encryptSecret seals plaintext with AES-GCM under TOTPKey. The random nonce is prepended to the result.
+func (app *Application) encryptSecret(plaintext []byte) ([]byte, error) {
+	gcm, err := app.totpCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptSecret opens a value produced by encryptSecret.
+func (app *Application) decryptSecret(ciphertext []byte) ([]byte, error) {
+	gcm, err := app.totpCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("encrypted secret is too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+// totpCipher builds the AES-GCM AEAD from the configured key.
+func (app *Application) totpCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(app.TOTPKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// verifyTOTP checks code against the secret for the current time step and one
+// step either side (to allow for clock drift). It returns the matching step.
+func verifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	for _, offset := range []int64{0, -1, 1} {
+		t := now.Add(time.Duration(offset*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totpValidateOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns fresh recovery codes and the hashes to store for them.
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < recoveryCodeCount; i++ {
+		randomBytes := make([]byte, 8)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(randomBytes))[:recoveryCodeLength]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalises a recovery code (case, spaces, dashes) and hashes it.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code for the user. Successful codes are burned so they cannot be reused.
+func (app *Application) verifySecondFactor(user *data.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	// Six digits is an authenticator code; anything else is treated as a recovery code
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		secret, err := app.decryptSecret(user.TOTPSecret)
+		if err != nil {
+			return false, err
+		}
+
+		step, ok := verifyTOTP(string(secret), code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return app.UserModel.UseTOTPStep(user.ID, step)
+	}
+
+	return app.UserModel.UseRecoveryCode(user.ID, hashRecoveryCode(code))
+}
@@ -0,0 +1,380 @@
+package app
+
+import (
+	"image/png"
+	"net/http"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// TwoFactorHandler shows the 2FA status page. When 2FA is off it starts an
+// enrollment by generating a secret, kept in the session until it is confirmed.
+func (app *Application) TwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	if user.TOTPEnabled() {
+		app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+			"Enabled":            true,
+			"RecoveryCodesCount": len(user.RecoveryCodes),
+		})
+		return
+	}
+
+	key, err := app.pendingTOTPKey(w, r)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+		"Secret": key.Secret(),
+	})
+}
+
+// TwoFactorQRHandler renders the pending enrollment's otpauth:// URL as a PNG QR code.
+func (app *Application) TwoFactorQRHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	keyURL, ok := session.Values[SessionPendingTOTPKey].(string)
+	if !ok {
+		app.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	key, err := otp.NewKeyFromURL(keyURL)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := png.Encode(w, img); err != nil {
+		app.ErrorLog.Output(2, err.Error())
+	}
+}
+
+// EnableTwoFactorHandler confirms the pending secret with a code from the
+// authenticator app, turns 2FA on and shows the recovery codes once.
+func (app *Application) EnableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	keyURL, ok := session.Values[SessionPendingTOTPKey].(string)
+	if !ok || user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	key, err := otp.NewKeyFromURL(keyURL)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	v := NewValidator()
+	_, valid := verifyTOTP(key.Secret(), r.PostForm.Get("code"), time.Now())
+	v.Check(valid, "code", "That code is not valid. Check your device's clock and try again")
+
+	if !v.Valid() {
+		app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+			"Secret": key.Secret(),
+			"Errors": v.Errors,
+		})
+		return
+	}
+
+	encrypted, err := app.encryptSecret([]byte(key.Secret()))
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	user.TOTPSecret = encrypted
+	user.RecoveryCodes = hashes
+	err = app.UserModel.EnableTOTP(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditTwoFactorOn, TargetType: "user", TargetID: user.ID})
+
+	delete(session.Values, SessionPendingTOTPKey)
+	if err := session.Save(r, w); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+		"Enabled":            true,
+		"RecoveryCodesCount": len(codes),
+		"RecoveryCodes":      codes,
+	})
+}
+
+// DisableTwoFactorHandler turns 2FA off after re-checking the password and a second factor.
+func (app *Application) DisableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+	if !user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	v := NewValidator()
+	v.Check(user.MatchesPassword(r.PostForm.Get("password")) == nil, "password", "Password is incorrect")
+	if v.Valid() {
+		ok, err := app.verifySecondFactor(user, r.PostForm.Get("code"))
+		if err != nil {
+			app.ServerError(w, err)
+			return
+		}
+		v.Check(ok, "code", "That code is not valid")
+	}
+
+	if !v.Valid() {
+		app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+			"Enabled":            true,
+			"RecoveryCodesCount": len(user.RecoveryCodes),
+			"Errors":             v.Errors,
+		})
+		return
+	}
+
+	err = app.UserModel.DisableTOTP(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditTwoFactorOff, TargetType: "user", TargetID: user.ID})
+
+	app.flashRedirect(w, r, "Two-factor authentication is now off.", "/account/2fa")
+}
+
+// RegenerateRecoveryCodesHandler replaces the recovery codes after re-checking the password.
+func (app *Application) RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+	if !user.TOTPEnabled() {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	v := NewValidator()
+	v.Check(user.MatchesPassword(r.PostForm.Get("password")) == nil, "regenerate_password", "Password is incorrect")
+
+	if !v.Valid() {
+		app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+			"Enabled":            true,
+			"RecoveryCodesCount": len(user.RecoveryCodes),
+			"Errors":             v.Errors,
+		})
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	user.RecoveryCodes = hashes
+	err = app.UserModel.SetRecoveryCodes(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	app.audit(r, auditEntry{Action: data.AuditRecoveryCodes, TargetType: "user", TargetID: user.ID})
+
+	app.Render(w, r, "two_factor.tmpl", map[string]interface{}{
+		"Enabled":            true,
+		"RecoveryCodesCount": len(codes),
+		"RecoveryCodes":      codes,
+	})
+}
+
+// LoginTwoFactorForm displays the second login step for users with 2FA enabled.
+func (app *Application) LoginTwoFactorForm(w http.ResponseWriter, r *http.Request) {
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	if _, ok := session.Values[SessionTwoFactorUserKey].(int); !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	app.Render(w, r, "login_2fa.tmpl", nil)
+}
+
+// LoginTwoFactorHandler checks the second factor for the user who passed the
+// password step and, if it is valid, authenticates the session.
+func (app *Application) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	userID, ok := session.Values[SessionTwoFactorUserKey].(int)
+	startedAt, _ := session.Values[SessionTwoFactorStartedKey].(int64)
+	if !ok || time.Since(time.Unix(startedAt, 0)) > twoFactorLoginTTL {
+		delete(session.Values, SessionTwoFactorUserKey)
+		delete(session.Values, SessionTwoFactorStartedKey)
+		session.AddFlash("Your login attempt expired. Please log in again.")
+		if err := session.Save(r, w); err != nil {
+			app.ServerError(w, err)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := app.UserModel.GetByID(userID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	// An admin may have stepped in since the password was checked
+	if user.IsDisabled() || user.PasswordResetRequired {
+		delete(session.Values, SessionTwoFactorUserKey)
+		delete(session.Values, SessionTwoFactorStartedKey)
+		if err := session.Save(r, w); err != nil {
+			app.ServerError(w, err)
+			return
+		}
+
+		reason, message := "disabled", "This account has been disabled."
+		if !user.IsDisabled() {
+			reason, message = "password_reset_required", "You need to choose a new password. Use the link we emailed you, or request a new one with \"Forgot your password?\"."
+		}
+		app.auditLoginFailure(r, user.Email, user, reason)
+		app.Render(w, r, "login.tmpl", map[string]interface{}{
+			"Error": message,
+			"Email": user.Email,
+		})
+		return
+	}
+
+	// Second-factor guesses count against the same limits as password guesses
+	ip := app.ClientIP(r)
+	wait, err := app.LoginThrottle.Check(ip, user.Email, user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if wait > 0 {
+		app.Render(w, r, "login_2fa.tmpl", map[string]interface{}{
+			"Error": "Too many attempts. Please try again in " + formatWait(wait) + ".",
+		})
+		return
+	}
+
+	valid, err := app.verifySecondFactor(user, r.PostForm.Get("code"))
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+	if !valid {
+		if err := app.LoginThrottle.Failure(ip, user.Email, user); err != nil {
+			app.ServerError(w, err)
+			return
+		}
+		app.auditLoginFailure(r, user.Email, user, "second_factor")
+		app.Render(w, r, "login_2fa.tmpl", map[string]interface{}{
+			"Error": "Invalid code",
+		})
+		return
+	}
+
+	if err := app.LoginThrottle.Success(user.Email, user); err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{
+		Action:     data.AuditLogin,
+		Actor:      user,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata:   map[string]interface{}{"second_factor": true},
+	})
+
+	app.logIn(w, r, user.ID)
+}
+
+// pendingTOTPKey returns the enrollment key stored in the session, generating
+// and storing a new one if there is none yet.
+func (app *Application) pendingTOTPKey(w http.ResponseWriter, r *http.Request) (*otp.Key, error) {
+	user := app.ContextGetUser(r)
+
+	session, err := app.SessionStore.Get(r, SessionName)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyURL, ok := session.Values[SessionPendingTOTPKey].(string); ok {
+		return otp.NewKeyFromURL(keyURL)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session.Values[SessionPendingTOTPKey] = key.URL()
+	return key, session.Save(r, w)
+}
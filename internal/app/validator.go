@@ -1,8 +1,13 @@
 package app
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
 )
 // emailRegex is a compiled regular expression for validating email format.
 var (
@@ -46,3 +51,34 @@ func MatchesPattern(value string, rx *regexp.Regexp) bool {
 func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
+// ValidateStory applies the title, content and status rules shared by the HTML forms and the JSON API.
+func ValidateStory(v *Validator, title, content, status string) {
+	v.Check(NotBlank(title), "title", "Title is required")
+	v.Check(len(title) >= 10 && len(title) <= 20, "title", "Title must be between 10-20 characters")
+	v.Check(NotBlank(content), "content", "Content is required")
+	v.Check(len(content) <= 500, "content", "Content must be 500 characters or less")
+	v.Check(data.ValidStoryStatus(status), "status", "Status must be draft, scheduled, private, unlisted or published")
+}
+
+// ValidateTags checks a story's normalised tags against the tag limits.
+func ValidateTags(v *Validator, tags []string) {
+	v.Check(len(tags) <= data.MaxStoryTags, "tags", fmt.Sprintf("Use at most %d tags", data.MaxStoryTags))
+	for _, tag := range tags {
+		v.Check(data.ValidTagLength(tag), "tags", fmt.Sprintf("Tags must be between %d and %d characters", data.MinTagLength, data.MaxTagLength))
+	}
+}
+
+// ValidateSchedule checks a scheduled story has a publication time later than now.
+func ValidateSchedule(v *Validator, status string, scheduledAt *time.Time, now time.Time) {
+	if status != data.StatusScheduled {
+		return
+	}
+	v.Check(scheduledAt != nil, "scheduled_at", "Choose when the story should be published")
+	v.Check(scheduledAt == nil || scheduledAt.After(now), "scheduled_at", "Publish time must be in the future")
+}
+
+// ValidateComment checks the body of a new or edited comment.
+func ValidateComment(v *Validator, body string) {
+	v.Check(NotBlank(body), "body", "Comment is required")
+	v.Check(utf8.RuneCountInString(body) <= data.MaxCommentLength, "body", fmt.Sprintf("Comments must be %d characters or less", data.MaxCommentLength))
+}
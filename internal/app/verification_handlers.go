@@ -0,0 +1,91 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// emailVerificationTTL is how long an email verification link stays valid.
+const emailVerificationTTL = 3 * 24 * time.Hour
+
+// sendVerificationEmail issues a verification token for the user and emails the link.
+func (app *Application) sendVerificationEmail(user *data.User) error {
+	token, err := app.OneTimeTokenModel.New(user.ID, data.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	app.SendEmail(user.Email, "verify_email.tmpl", map[string]interface{}{
+		"URL": app.BaseURL + "/verify-email?token=" + url.QueryEscape(token.Plaintext),
+		"TTL": "3 days",
+	})
+	return nil
+}
+
+// VerifyEmailHandler consumes a verification link and marks the address as verified.
+func (app *Application) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.OneTimeTokenModel.Consume(r.URL.Query().Get("token"), data.PurposeEmailVerification)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			app.Render(w, r, "verify_email.tmpl", map[string]interface{}{
+				"InvalidToken": true,
+			})
+			return
+		}
+		app.ServerError(w, err)
+		return
+	}
+
+	user, err := app.UserModel.GetByID(userID)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.UserModel.MarkEmailVerified(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	err = app.OneTimeTokenModel.DeleteAllForUser(user.ID, data.PurposeEmailVerification)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.audit(r, auditEntry{Action: data.AuditEmailVerified, Actor: user, TargetType: "user", TargetID: user.ID})
+
+	app.flashRedirect(w, r, "Thanks, your email address is verified.", "/")
+}
+
+// VerifyEmailNotice explains that the address needs verifying and offers to resend the link.
+func (app *Application) VerifyEmailNotice(w http.ResponseWriter, r *http.Request) {
+	if app.ContextGetUser(r).IsVerified() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	app.Render(w, r, "verify_email.tmpl", nil)
+}
+
+// ResendVerificationHandler emails a fresh verification link to the current user.
+func (app *Application) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.ContextGetUser(r)
+	if user.IsVerified() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	err := app.sendVerificationEmail(user)
+	if err != nil {
+		app.ServerError(w, err)
+		return
+	}
+
+	app.flashRedirect(w, r, "We have sent a new verification link to "+user.Email+".", "/account/verify")
+}
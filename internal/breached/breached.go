@@ -0,0 +1,132 @@
+// Package breached checks passwords against a locally stored corpus of
+// passwords known from data breaches, identified by their SHA-1 hashes in the
+// format published by Have I Been Pwned. No network access is needed.
+package breached
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Corpus reports whether a password appears in a breach.
+type Corpus interface {
+	Contains(password string) (bool, error)
+}
+
+// Open returns a corpus for path. A directory is treated as a PrefixDir and a
+// file is loaded into memory with LoadFile.
+func Open(path string) (Corpus, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return PrefixDir(path), nil
+	}
+	return LoadFile(path)
+}
+
+// hash returns the upper-case hex SHA-1 of password, as used by the corpus files.
+func hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// PrefixDir is a directory of range files named after the first five hex
+// characters of the SHA-1 hash (e.g. "5BAA6.txt"). Each line holds the
+// remaining 35 characters and an optional count: "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493".
+// Only the one small file for the password's prefix is read per lookup.
+type PrefixDir string
+
+// Contains implements Corpus.
+func (d PrefixDir) Contains(password string) (bool, error) {
+	h := hash(password)
+
+	f, err := os.Open(filepath.Join(string(d), h[:5]+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	suffix := h[5:]
+	found := false
+	err = scanHashes(f, func(line string) bool {
+		found = strings.EqualFold(line, suffix)
+		return !found
+	})
+	return found, err
+}
+
+// Set is an in-memory corpus of full SHA-1 hashes.
+type Set map[[sha1.Size]byte]struct{}
+
+// LoadFile reads a file with one full hex SHA-1 hash per line, optionally
+// followed by ":count". Blank lines and lines starting with # are ignored.
+func LoadFile(path string) (Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set := make(Set)
+	entry := 0
+	var parseErr error
+	err = scanHashes(f, func(line string) bool {
+		entry++
+		var key [sha1.Size]byte
+		if len(line) != hex.EncodedLen(sha1.Size) || hexDecode(key[:], line) != nil {
+			parseErr = fmt.Errorf("%s: entry %d is not a SHA-1 hash", path, entry)
+			return false
+		}
+		set[key] = struct{}{}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return set, nil
+}
+
+// hexDecode decodes a hex string into dst, which must be exactly the right size.
+func hexDecode(dst []byte, s string) error {
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Contains implements Corpus.
+func (s Set) Contains(password string) (bool, error) {
+	_, ok := s[sha1.Sum([]byte(password))]
+	return ok, nil
+}
+
+// scanHashes calls fn with the hash part of each non-empty, non-comment line
+// until fn returns false.
+func scanHashes(r io.Reader, fn func(string) bool) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line, _, _ = strings.Cut(line, ":")
+		if !fn(line) {
+			break
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,53 @@
+// Package clock abstracts the current time so time-dependent code can be
+// driven deterministically in tests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock reports the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the real wall clock.
+type System struct{}
+
+// Now returns time.Now().
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Manual is a clock that only moves when told to. It is safe for concurrent use.
+type Manual struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManual returns a Manual clock set to t.
+func NewManual(t time.Time) *Manual {
+	return &Manual{now: t}
+}
+
+// Now returns the clock's current time.
+func (c *Manual) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Manual) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to t.
+func (c *Manual) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions. Names are "<subject>.<verb>" so related events sort together.
+const (
+	AuditLogin          = "user.login"
+	AuditLoginFailed    = "user.login_failed"
+	AuditLocked         = "user.locked"
+	AuditLogout         = "user.logout"
+	AuditSignup         = "user.signup"
+	AuditEmailVerified  = "user.email_verified"
+	AuditPasswordChange = "user.password_changed"
+	AuditPasswordReset  = "user.password_reset"
+	AuditTwoFactorOn    = "user.2fa_enabled"
+	AuditTwoFactorOff   = "user.2fa_disabled"
+	AuditRecoveryCodes  = "user.2fa_recovery_codes_regenerated"
+	AuditTokenCreate    = "token.created"
+	AuditTokenRevoke    = "token.revoked"
+	AuditSessionRevoke  = "session.revoked"
+
+	AuditStoryCreate  = "story.created"
+	AuditStoryUpdate  = "story.updated"
+	AuditStoryDelete  = "story.deleted"
+	AuditStoryRestore = "story.restored"
+	AuditStoryPurge   = "story.purged"
+	AuditStoryReport  = "story.reported"
+	AuditStoryHidden  = "story.auto_hidden"
+
+	AuditStoryCommentsLock   = "story.comments_locked"
+	AuditStoryCommentsUnlock = "story.comments_unlocked"
+	AuditCommentCreate       = "comment.created"
+	AuditCommentUpdate       = "comment.updated"
+	AuditCommentDelete       = "comment.deleted"
+
+	AuditModDismiss = "moderation.reports_dismissed"
+	AuditModHide    = "moderation.story_hidden"
+	AuditModSuspend = "moderation.author_suspended"
+
+	AuditAdminSetRole      = "admin.user_role_changed"
+	AuditAdminDisableUser  = "admin.user_disabled"
+	AuditAdminEnableUser   = "admin.user_enabled"
+	AuditAdminForceReset   = "admin.user_password_reset_forced"
+	AuditAdminDeleteStory  = "admin.story_deleted"
+	AuditAdminRestoreStory = "admin.story_restored"
+	AuditAdminPurgeStory   = "admin.story_purged"
+)
+
+// AuditActions lists every action, for filtering in the viewer
+var AuditActions = []string{
+	AuditLogin, AuditLoginFailed, AuditLocked, AuditLogout, AuditSignup, AuditEmailVerified,
+	AuditPasswordChange, AuditPasswordReset, AuditTwoFactorOn, AuditTwoFactorOff, AuditRecoveryCodes,
+	AuditTokenCreate, AuditTokenRevoke, AuditSessionRevoke,
+	AuditStoryCreate, AuditStoryUpdate, AuditStoryDelete, AuditStoryRestore, AuditStoryPurge,
+	AuditStoryReport, AuditStoryHidden,
+	AuditStoryCommentsLock, AuditStoryCommentsUnlock, AuditCommentCreate, AuditCommentUpdate, AuditCommentDelete,
+	AuditModDismiss, AuditModHide, AuditModSuspend,
+	AuditAdminSetRole, AuditAdminDisableUser, AuditAdminEnableUser, AuditAdminForceReset,
+	AuditAdminDeleteStory, AuditAdminRestoreStory, AuditAdminPurgeStory,
+}
+
+// AuditTargetTypes lists the kinds of record an event can target
+var AuditTargetTypes = []string{"user", "story", "comment", "token", "session"}
+
+// AuditEvent is one entry in the append-only audit log. Before and After hold
+// the fields of the target that changed; Metadata holds any other context.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Action     string          `json:"action"`
+	ActorID    *int            `json:"actor_id"`
+	ActorEmail string          `json:"actor_email"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+// AuditFilter narrows the audit log. Empty fields match everything.
+type AuditFilter struct {
+	Action     string
+	Actor      string // matched against the actor's email
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// AuditModel wraps a sql.DB connection pool for working with the audit log
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Insert appends an event to the log and sets its ID and created_at fields
+func (m *AuditModel) Insert(e *AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (action, actor_id, actor_email, target_type, target_id,
+			ip, user_agent, before, after, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`
+
+	return m.DB.QueryRow(query, e.Action, e.ActorID, e.ActorEmail, e.TargetType, e.TargetID,
+		e.IP, e.UserAgent, nullJSON(e.Before), nullJSON(e.After), nullJSON(e.Metadata),
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+// List returns one page of events matching the filter, newest first
+func (m *AuditModel) List(filter AuditFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), id, created_at, action, actor_id, actor_email, target_type, target_id,
+			ip, user_agent, before, after, metadata
+		FROM audit_events
+		WHERE ($1 = '' OR action = $1)
+		AND ($2 = '' OR actor_email ILIKE $3)
+		AND ($4 = '' OR target_type = $4)
+		AND ($5 = '' OR target_id = $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6)
+		AND ($7::timestamptz IS NULL OR created_at < $7)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $8 OFFSET $9`
+
+	actorPattern := Filters{Search: filter.Actor}.searchPattern()
+	rows, err := m.DB.Query(query, filter.Action, filter.Actor, actorPattern, filter.TargetType, filter.TargetID,
+		filter.From, filter.To, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	var events []*AuditEvent
+	for rows.Next() {
+		var e AuditEvent
+		var before, after, metadata []byte
+		err := rows.Scan(&totalRecords, &e.ID, &e.CreatedAt, &e.Action, &e.ActorID, &e.ActorEmail,
+			&e.TargetType, &e.TargetID, &e.IP, &e.UserAgent, &before, &after, &metadata)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		e.Before, e.After, e.Metadata = before, after, metadata
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return events, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// nullJSON stores empty JSON as SQL NULL
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	// Sent as text: lib/pq would send []byte in binary form, which jsonb does not accept
+	return string(raw)
+}
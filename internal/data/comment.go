@@ -0,0 +1,77 @@
+package data
+
+import "time"
+
+// MaxCommentLength caps the length of a comment's body, in characters
+const MaxCommentLength = 2000
+
+// MaxCommentIndent is the deepest level a reply is indented to; deeper replies line up with it
+const MaxCommentIndent = 5
+
+// Comment is a reader's response to a story, or to another comment on it
+type Comment struct {
+	ID        int64      `json:"id"`
+	StoryID   int        `json:"story_id"`
+	UserID    int        `json:"user_id"`
+	ParentID  *int64     `json:"parent_id"` // the comment this one replies to, if any
+	Body      string     `json:"body"`      // blank once the comment is deleted
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"-"` // set once the comment is deleted; it stays to keep its replies threaded
+	UserEmail string     `json:"user_email"`
+	Depth     int        `json:"depth"` // 0 for a top-level comment, 1 for a reply to one, and so on
+}
+
+// IsDeleted reports whether the comment has been deleted
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// IsEdited reports whether the comment was changed after it was posted
+func (c *Comment) IsEdited() bool {
+	return c.UpdatedAt.After(c.CreatedAt)
+}
+
+// Indent is the Depth the comment is shown at, capped at MaxCommentIndent
+func (c *Comment) Indent() int {
+	return min(c.Depth, MaxCommentIndent)
+}
+
+// threadComments puts comments, given oldest first, into reading order: each
+// comment followed by its replies, oldest first, with Depth set. Deleted
+// comments are left out unless something below them is still there.
+func threadComments(comments []*Comment) []*Comment {
+	replies := make(map[int64][]*Comment)
+	var roots []*Comment
+	for _, c := range comments {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			replies[*c.ParentID] = append(replies[*c.ParentID], c)
+		}
+	}
+
+	var thread []*Comment
+	var walk func(c *Comment, depth int) bool
+	walk = func(c *Comment, depth int) bool {
+		c.Depth = depth
+		at := len(thread)
+		thread = append(thread, c)
+
+		kept := false
+		for _, reply := range replies[c.ID] {
+			if walk(reply, depth+1) {
+				kept = true
+			}
+		}
+		if c.IsDeleted() && !kept {
+			thread = thread[:at]
+			return false
+		}
+		return true
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return thread
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// CommentModel wraps a sql.DB connection pool for working with comments
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// commentColumns is the column list every query that loads comments selects,
+// in the order expected by commentDest. Queries must join users for the author's email.
+const commentColumns = `comments.id, comments.story_id, comments.user_id, comments.parent_id, comments.body,
+	comments.created_at, comments.updated_at, comments.deleted_at, users.email`
+
+// commentDest returns the scan destinations matching commentColumns
+func commentDest(comment *Comment) []interface{} {
+	return []interface{}{
+		&comment.ID,
+		&comment.StoryID,
+		&comment.UserID,
+		&comment.ParentID,
+		&comment.Body,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.DeletedAt,
+		&comment.UserEmail,
+	}
+}
+
+// Insert adds a comment and sets its ID and timestamps. The caller checks the
+// parent, if any, belongs to the same story.
+func (m *CommentModel) Insert(comment *Comment) error {
+	query := `
+		INSERT INTO comments (story_id, user_id, parent_id, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+
+	return m.DB.QueryRow(query, comment.StoryID, comment.UserID, comment.ParentID, comment.Body).Scan(
+		&comment.ID,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+}
+
+// Get returns a comment by its ID. Deleted comments are found too, so callers
+// can tell them apart from missing ones.
+func (m *CommentModel) Get(id int64) (*Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		INNER JOIN users ON comments.user_id = users.id
+		WHERE comments.id = $1`
+
+	var comment Comment
+	err := m.DB.QueryRow(query, id).Scan(commentDest(&comment)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// Thread returns the comments on a story in reading order, each followed by
+// its replies, with Depth set. Deleted comments only appear when they have
+// replies still showing.
+func (m *CommentModel) Thread(storyID int) ([]*Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		INNER JOIN users ON comments.user_id = users.id
+		WHERE comments.story_id = $1
+		ORDER BY comments.created_at, comments.id`
+
+	rows, err := m.DB.Query(query, storyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []*Comment
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(commentDest(&comment)...); err != nil {
+			return nil, err
+		}
+		comments = append(comments, &comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return threadComments(comments), nil
+}
+
+// Update saves a new body for a comment and sets its updated_at field.
+// Deleted comments cannot be edited and are reported as not found.
+func (m *CommentModel) Update(comment *Comment) error {
+	query := `
+		UPDATE comments
+		SET body = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING updated_at`
+
+	err := m.DB.QueryRow(query, comment.Body, comment.ID).Scan(&comment.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete blanks a comment and marks it deleted, keeping the row so replies to it stay in place
+func (m *CommentModel) Delete(id int64) error {
+	result, err := m.DB.Exec(`UPDATE comments SET body = '', deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a listing ordered by a timestamp and then by id,
+// so pages stay put when new records are added and need no OFFSET to reach.
+type Cursor struct {
+	Time time.Time
+	ID   int
+}
+
+// Encode returns the cursor as an opaque, URL-safe string.
+func (c Cursor) Encode() string {
+	raw := c.Time.UTC().Format(time.RFC3339Nano) + "," + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a string made by Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	stamp, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	c.Time, err = time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	c.ID, err = strconv.Atoi(id)
+	if err != nil || c.ID < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// CursorFilters selects one page of a cursor-paginated listing. With neither
+// cursor the listing starts from the newest record; Before wins if both are set.
+type CursorFilters struct {
+	After    string // cursor of the record just before the page, for the next (older) page
+	Before   string // cursor of the record just after the page, for the previous (newer) page
+	PageSize int
+}
+
+// CursorMetadata describes one page of a cursor-paginated listing. The cursors
+// are empty when there is nothing further in that direction.
+type CursorMetadata struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// HasNext reports whether there is an older page
+func (m CursorMetadata) HasNext() bool {
+	return m.NextCursor != ""
+}
+
+// HasPrev reports whether there is a newer page
+func (m CursorMetadata) HasPrev() bool {
+	return m.PrevCursor != ""
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Time: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), ID: 1},
+		{Time: time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.UTC), ID: 42},
+		{Time: time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("EST", -5*60*60)), ID: 7},
+	}
+
+	for _, want := range cursors {
+		got, err := DecodeCursor(want.Encode())
+		if err != nil {
+			t.Fatalf("DecodeCursor(%q): %v", want.Encode(), err)
+		}
+		if !got.Time.Equal(want.Time) || got.ID != want.ID {
+			t.Errorf("DecodeCursor(Encode(%v)) = %v", want, got)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("2024-03-01T09:30:00Z,1"))},
+		{"no separator", encode("2024-03-01T09:30:00Z")},
+		{"bad time", encode("yesterday,1")},
+		{"time without zone", encode("2024-03-01T09:30:00,1")},
+		{"bad id", encode("2024-03-01T09:30:00Z,one")},
+		{"zero id", encode("2024-03-01T09:30:00Z,0")},
+		{"negative id", encode("2024-03-01T09:30:00Z,-3")},
+		{"extra field", encode("2024-03-01T09:30:00Z,1,2")},
+		{"sql", encode("2024-03-01T09:30:00Z,1 OR 1=1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodeCursor(tt.in); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor(%q) = %v, %v; want ErrInvalidCursor", tt.in, c, err)
+			}
+		})
+	}
+}
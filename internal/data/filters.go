@@ -0,0 +1,76 @@
+package data
+
+import (
+	"math"
+	"strings"
+)
+
+// Filters holds the search, sort and paging options for listing queries
+type Filters struct {
+	Search   string
+	Sort     string // a key of the model's sort safelist, prefixed with "-" for descending order
+	Page     int
+	PageSize int
+}
+
+// Metadata describes one page of a filtered listing
+type Metadata struct {
+	CurrentPage  int `json:"current_page"`
+	PageSize     int `json:"page_size"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+// HasPrev reports whether there is a page before the current one
+func (m Metadata) HasPrev() bool {
+	return m.CurrentPage > 1
+}
+
+// HasNext reports whether there is a page after the current one
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
+
+// orderBy turns Sort into an ORDER BY expression using the safelist of sort
+// keys to columns, falling back to fallback for unknown keys. Callers should
+// add the id as a tie-breaker so pages are stable.
+func (f Filters) orderBy(safelist map[string]string, fallback string) string {
+	key := strings.TrimPrefix(f.Sort, "-")
+	column, ok := safelist[key]
+	if !ok {
+		return fallback
+	}
+
+	direction := "ASC"
+	if strings.HasPrefix(f.Sort, "-") {
+		direction = "DESC"
+	}
+	return column + " " + direction
+}
+
+// searchPattern returns an ILIKE pattern matching Search anywhere, with its wildcards escaped
+func (f Filters) searchPattern() string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.TrimSpace(f.Search))
+	return "%" + escaped + "%"
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// calculateMetadata works out the paging details from the total number of matching records
+func calculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{CurrentPage: page, PageSize: pageSize, LastPage: 1}
+	}
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+}
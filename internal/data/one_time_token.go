@@ -0,0 +1,19 @@
+package data
+
+import "time"
+
+// Purposes a one-time token can be issued for.
+const (
+	PurposePasswordReset     = "password-reset"
+	PurposeEmailVerification = "email-verification"
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user by email. Only
+// its SHA-256 hash is stored.
+type OneTimeToken struct {
+	Plaintext string
+	Hash      []byte
+	UserID    int
+	Purpose   string
+	ExpiresAt time.Time
+}
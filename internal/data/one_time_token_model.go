@@ -0,0 +1,83 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// OneTimeTokenModel wraps a sql.DB connection pool for working with single-use email tokens
+type OneTimeTokenModel struct {
+	DB *sql.DB
+}
+
+// New generates a token for the given purpose that expires after ttl and stores its hash
+func (m *OneTimeTokenModel) New(userID int, purpose string, ttl time.Duration) (*OneTimeToken, error) {
+	plaintext, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &OneTimeToken{
+		Plaintext: plaintext,
+		Hash:      hashToken(plaintext),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	query := `
+		INSERT INTO one_time_tokens (hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)`
+
+	_, err = m.DB.Exec(query, token.Hash, token.UserID, token.Purpose, token.ExpiresAt)
+	return token, err
+}
+
+// GetUserForToken returns the owner of an unused, unexpired token without consuming it
+func (m *OneTimeTokenModel) GetUserForToken(plaintext, purpose string) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM one_time_tokens
+		INNER JOIN users ON one_time_tokens.user_id = users.id
+		WHERE one_time_tokens.hash = $1
+		AND one_time_tokens.purpose = $2
+		AND one_time_tokens.used_at IS NULL
+		AND one_time_tokens.expires_at > NOW()`
+
+	var user User
+	err := m.DB.QueryRow(query, hashToken(plaintext), purpose).Scan(userDest(&user)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Consume marks an unused, unexpired token as used and returns its owner's ID.
+// The update is atomic, so a token can only ever be consumed once.
+func (m *OneTimeTokenModel) Consume(plaintext, purpose string) (int, error) {
+	query := `
+		UPDATE one_time_tokens
+		SET used_at = NOW()
+		WHERE hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`
+
+	var userID int
+	err := m.DB.QueryRow(query, hashToken(plaintext), purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrRecordNotFound
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// DeleteAllForUser removes every token of the given purpose belonging to the user
+func (m *OneTimeTokenModel) DeleteAllForUser(userID int, purpose string) error {
+	_, err := m.DB.Exec(`DELETE FROM one_time_tokens WHERE user_id = $1 AND purpose = $2`, userID, purpose)
+	return err
+}
@@ -0,0 +1,233 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrMismatchedPassword is returned when a password does not match its hash
+	ErrMismatchedPassword = errors.New("password does not match")
+	// ErrUnknownHashFormat is returned when no configured hasher recognises a stored hash
+	ErrUnknownHashFormat = errors.New("unrecognised password hash format")
+)
+
+// PasswordHasher hashes and verifies passwords in one self-describing encoded format
+type PasswordHasher interface {
+	// Hash returns the encoded hash of plaintext, including its salt and parameters
+	Hash(plaintext string) (string, error)
+	// Verify reports whether plaintext matches an encoded hash produced by this hasher
+	Verify(encoded, plaintext string) (bool, error)
+	// Recognizes reports whether encoded was produced by this kind of hasher
+	Recognizes(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker parameters than the hasher's current ones
+	NeedsRehash(encoded string) bool
+}
+
+// PasswordHashing hashes new passwords with Current and verifies stored hashes
+// with whichever of Current or Legacy recognises them.
+type PasswordHashing struct {
+	Current PasswordHasher
+	Legacy  []PasswordHasher
+}
+
+// Passwords is the hashing policy used by User.SetPassword and User.MatchesPassword
+var Passwords = &PasswordHashing{
+	Current: DefaultArgon2id(),
+	Legacy:  []PasswordHasher{&BcryptHasher{Cost: 12}},
+}
+
+// Hash hashes plaintext with the current hasher
+func (p *PasswordHashing) Hash(plaintext string) (string, error) {
+	return p.Current.Hash(plaintext)
+}
+
+// Verify checks plaintext against an encoded hash in any supported format.
+// It returns ErrMismatchedPassword when the password is wrong.
+func (p *PasswordHashing) Verify(encoded, plaintext string) error {
+	hasher := p.hasherFor(encoded)
+	if hasher == nil {
+		return ErrUnknownHashFormat
+	}
+
+	ok, err := hasher.Verify(encoded, plaintext)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMismatchedPassword
+	}
+	return nil
+}
+
+// NeedsRehash reports whether encoded should be replaced by a hash from the
+// current hasher, either because it is in a legacy format or because the
+// current hasher's parameters have been raised since it was made.
+func (p *PasswordHashing) NeedsRehash(encoded string) bool {
+	if !p.Current.Recognizes(encoded) {
+		return true
+	}
+	return p.Current.NeedsRehash(encoded)
+}
+
+func (p *PasswordHashing) hasherFor(encoded string) PasswordHasher {
+	if p.Current.Recognizes(encoded) {
+		return p.Current
+	}
+	for _, hasher := range p.Legacy {
+		if hasher.Recognizes(encoded) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded in the PHC string
+// format: $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2id returns an argon2id hasher with the parameters recommended by
+// RFC 9106 for memory-constrained environments (64 MiB, 3 passes).
+func DefaultArgon2id() *Argon2idHasher {
+	return &Argon2idHasher{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// argon2idParams are the values decoded from a stored argon2id hash
+type argon2idParams struct {
+	memory, iterations uint32
+	parallelism        uint8
+	salt, key          []byte
+}
+
+var b64 = base64.RawStdEncoding
+
+// Hash implements PasswordHasher
+func (h *Argon2idHasher) Hash(plaintext string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(plaintext), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify implements PasswordHasher, using the parameters stored in the hash
+func (h *Argon2idHasher) Verify(encoded, plaintext string) (bool, error) {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(plaintext), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// Recognizes implements PasswordHasher
+func (h *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory < h.Memory ||
+		params.iterations < h.Iterations ||
+		params.parallelism != h.Parallelism ||
+		uint32(len(params.salt)) < h.SaltLength ||
+		uint32(len(params.key)) < h.KeyLength
+}
+
+// decodeArgon2id parses a hash produced by Argon2idHasher.Hash
+func decodeArgon2id(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	params := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+
+	var err error
+	if params.salt, err = b64.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownHashFormat
+	}
+	if params.key, err = b64.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, ErrUnknownHashFormat
+	}
+
+	return params, nil
+}
+
+// BcryptHasher hashes passwords with bcrypt. bcrypt ignores everything past
+// the 72nd byte of a password, so it is kept to verify existing hashes.
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash implements PasswordHasher
+func (h *BcryptHasher) Hash(plaintext string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify implements PasswordHasher
+func (h *BcryptHasher) Verify(encoded, plaintext string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plaintext))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Recognizes implements PasswordHasher
+func (h *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// NeedsRehash implements PasswordHasher
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
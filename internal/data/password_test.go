@@ -0,0 +1,156 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id is cheap enough to hash with in tests
+func testArgon2id() *Argon2idHasher {
+	return &Argon2idHasher{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		want    *argon2idParams
+	}{
+		{"valid", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5",
+			&argon2idParams{memory: 65536, iterations: 3, parallelism: 2, salt: []byte("saltsalt"), key: []byte("keykeykey")}},
+		{"other algorithm", "$argon2i$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5", nil},
+		{"bcrypt", "$2a$12$R9h/cIPz0gi.URNNX3kh2OPST9/PgBkqquzi.Ss7KIUgO2t0jWMUW", nil},
+		{"missing key", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ", nil},
+		{"empty key", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$", nil},
+		{"old version", "$argon2id$v=16$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5", nil},
+		{"bad version", "$argon2id$19$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5", nil},
+		{"bad parameters", "$argon2id$v=19$t=3,m=65536,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5", nil},
+		{"bad salt", "$argon2id$v=19$m=65536,t=3,p=2$not*base64$a2V5a2V5a2V5", nil},
+		{"padded key", "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5a2U=", nil},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeArgon2id(tt.encoded)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("decodeArgon2id(%q) = %+v; want an error", tt.encoded, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeArgon2id(%q): %v", tt.encoded, err)
+			}
+			if got.memory != tt.want.memory || got.iterations != tt.want.iterations || got.parallelism != tt.want.parallelism ||
+				string(got.salt) != string(tt.want.salt) || string(got.key) != string(tt.want.key) {
+				t.Errorf("decodeArgon2id(%q) = %+v; want %+v", tt.encoded, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := testArgon2id()
+
+	encoded, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Recognizes(encoded) {
+		t.Errorf("Recognizes(%q) = false", encoded)
+	}
+	if h.NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash(%q) = true straight after hashing", encoded)
+	}
+
+	for plaintext, want := range map[string]bool{
+		"correct horse battery staple":  true,
+		"correct horse battery staple ": false,
+		"":                              false,
+	} {
+		ok, err := h.Verify(encoded, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("Verify(%q) = %t; want %t", plaintext, ok, want)
+		}
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	encoded, err := testArgon2id().Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(h *Argon2idHasher)
+		want   bool
+	}{
+		{"same parameters", func(h *Argon2idHasher) {}, false},
+		{"more memory", func(h *Argon2idHasher) { h.Memory *= 2 }, true},
+		{"less memory", func(h *Argon2idHasher) { h.Memory /= 2 }, false},
+		{"more iterations", func(h *Argon2idHasher) { h.Iterations++ }, true},
+		{"different parallelism", func(h *Argon2idHasher) { h.Parallelism++ }, true},
+		{"longer salt", func(h *Argon2idHasher) { h.SaltLength *= 2 }, true},
+		{"longer key", func(h *Argon2idHasher) { h.KeyLength *= 2 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testArgon2id()
+			tt.change(h)
+			if got := h.NeedsRehash(encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %t; want %t", got, tt.want)
+			}
+		})
+	}
+
+	if !testArgon2id().NeedsRehash("$argon2id$v=19$garbage") {
+		t.Error("NeedsRehash of a malformed hash = false; want true")
+	}
+}
+
+func TestPasswordHashing(t *testing.T) {
+	legacy := &BcryptHasher{Cost: bcrypt.MinCost}
+	p := &PasswordHashing{Current: testArgon2id(), Legacy: []PasswordHasher{legacy}}
+
+	bcryptHash, err := legacy.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := p.Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		encoded   string
+		plaintext string
+		wantErr   error
+		rehash    bool
+	}{
+		{"current format", argonHash, "hunter2", nil, false},
+		{"current format, wrong password", argonHash, "hunter3", ErrMismatchedPassword, false},
+		{"legacy format", bcryptHash, "hunter2", nil, true},
+		{"legacy format, wrong password", bcryptHash, "hunter3", ErrMismatchedPassword, true},
+		{"unknown format", "plaintext", "plaintext", ErrUnknownHashFormat, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.Verify(tt.encoded, tt.plaintext); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v; want %v", err, tt.wantErr)
+			}
+			if got := p.NeedsRehash(tt.encoded); got != tt.rehash {
+				t.Errorf("NeedsRehash = %t; want %t", got, tt.rehash)
+			}
+		})
+	}
+}
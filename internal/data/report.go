@@ -0,0 +1,72 @@
+package data
+
+import "time"
+
+// Reasons a reader can give when reporting a story
+const (
+	ReportSpam       = "spam"
+	ReportHarassment = "harassment"
+	ReportHate       = "hate"
+	ReportViolence   = "violence"
+	ReportSexual     = "sexual"
+	ReportOther      = "other"
+)
+
+// ReportReasons lists every reason in the order they are offered to readers
+var ReportReasons = []string{ReportSpam, ReportHarassment, ReportHate, ReportViolence, ReportSexual, ReportOther}
+
+// ReportReasonLabels describes each reason for readers and moderators
+var ReportReasonLabels = map[string]string{
+	ReportSpam:       "Spam or advertising",
+	ReportHarassment: "Harassment or bullying",
+	ReportHate:       "Hate speech",
+	ReportViolence:   "Violence or threats",
+	ReportSexual:     "Sexual content",
+	ReportOther:      "Something else",
+}
+
+// ValidReportReason reports whether reason is one of ReportReasons
+func ValidReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Ways a moderator can resolve the reports against a story
+const (
+	ResolutionDismissed       = "dismissed"
+	ResolutionStoryHidden     = "story_hidden"
+	ResolutionAuthorSuspended = "author_suspended"
+)
+
+// Report is one reader's complaint about a story
+type Report struct {
+	ID         int64
+	StoryID    int
+	ReporterID int
+	Reason     string
+	Details    string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+	ResolvedBy *int
+	Resolution *string
+}
+
+// ReasonCount is how many open reports against a story gave a reason
+type ReasonCount struct {
+	Reason string
+	Count  int
+}
+
+// ReportGroup gathers the open reports against one story for the moderation queue
+type ReportGroup struct {
+	Story           *Story
+	Count           int
+	Reasons         []ReasonCount // only reasons that were given, in ReportReasons order
+	Details         []string      // non-empty details, oldest first
+	FirstReportedAt time.Time
+	LastReportedAt  time.Time
+}
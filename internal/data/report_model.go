@@ -0,0 +1,118 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateReport is returned when a reader reports a story they already have an open report against
+var ErrDuplicateReport = errors.New("duplicate report")
+
+// ReportModel wraps a sql.DB connection pool for working with story reports
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert files a report and sets its ID and created_at fields
+func (m *ReportModel) Insert(report *Report) error {
+	query := `
+		INSERT INTO reports (story_id, reporter_id, reason, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := m.DB.QueryRow(query, report.StoryID, report.ReporterID, report.Reason, report.Details).Scan(
+		&report.ID,
+		&report.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "reports_open_story_reporter_idx"`:
+			return ErrDuplicateReport
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenCount returns the number of unresolved reports against a story
+func (m *ReportModel) OpenCount(storyID int) (int, error) {
+	var count int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM reports WHERE story_id = $1 AND resolved_at IS NULL`, storyID).Scan(&count)
+	return count, err
+}
+
+// Queue returns one page of stories with unresolved reports, most reported first.
+// Reports against deleted stories wait until the story is restored.
+func (m *ReportModel) Queue(filters Filters) ([]*ReportGroup, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `,
+			COUNT(reports.id), MIN(reports.created_at), MAX(reports.created_at),
+			array_agg(reports.reason),
+			array_agg(reports.details ORDER BY reports.created_at) FILTER (WHERE reports.details <> '')
+		FROM reports
+		INNER JOIN stories ON reports.story_id = stories.id
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE reports.resolved_at IS NULL AND stories.deleted_at IS NULL
+		GROUP BY stories.id, users.id
+		ORDER BY COUNT(reports.id) DESC, MAX(reports.created_at) DESC, stories.id DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := m.DB.Query(query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	var groups []*ReportGroup
+	for rows.Next() {
+		group := ReportGroup{Story: &Story{}}
+		var reasons []string
+		dest := append([]interface{}{&totalRecords}, storyDest(group.Story)...)
+		dest = append(dest, &group.Count, &group.FirstReportedAt, &group.LastReportedAt,
+			pq.Array(&reasons), pq.Array(&group.Details))
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		group.Reasons = countReasons(reasons)
+		groups = append(groups, &group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return groups, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Resolve closes every open report against a story, recording the moderator and outcome
+func (m *ReportModel) Resolve(storyID, moderatorID int, resolution string) error {
+	query := `
+		UPDATE reports
+		SET resolved_at = NOW(), resolved_by = $1, resolution = $2
+		WHERE story_id = $3 AND resolved_at IS NULL`
+
+	result, err := m.DB.Exec(query, moderatorID, resolution, storyID)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// countReasons tallies reasons in ReportReasons order, leaving out those never given
+func countReasons(reasons []string) []ReasonCount {
+	counts := make(map[string]int)
+	for _, reason := range reasons {
+		counts[reason]++
+	}
+
+	var tally []ReasonCount
+	for _, reason := range ReportReasons {
+		if counts[reason] > 0 {
+			tally = append(tally, ReasonCount{Reason: reason, Count: counts[reason]})
+		}
+	}
+	return tally
+}
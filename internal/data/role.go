@@ -0,0 +1,32 @@
+package data
+
+// Role is a user's staff level. Each role includes the permissions of the ones below it.
+type Role string
+
+// Roles in ascending order of privilege
+const (
+	RoleMember    Role = "member"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// roleRanks orders the roles so they can be compared
+var roleRanks = map[Role]int{
+	RoleMember:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Roles lists every role, lowest first
+var Roles = []Role{RoleMember, RoleModerator, RoleAdmin}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := roleRanks[Role(role)]
+	return ok
+}
+
+// AtLeast reports whether r grants at least the privileges of other
+func (r Role) AtLeast(other Role) bool {
+	return roleRanks[r] >= roleRanks[other] && roleRanks[r] > 0
+}
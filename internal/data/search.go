@@ -0,0 +1,423 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// ErrBlankSearch is returned by Search when the search text has no words to look for
+var ErrBlankSearch = errors.New("blank search")
+
+// Markers ts_headline puts around matched words. They are private-use
+// characters, so they never clash with story text and are split out before
+// anything is rendered.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// Fragment is a run of highlighted text; Match is set for the words that matched the search.
+type Fragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// SearchResult is a story found by Search, with its title and an excerpt of its
+// content broken into fragments so the matching words can be highlighted.
+type SearchResult struct {
+	Story   *Story     `json:"story"`
+	Rank    float64    `json:"rank"`
+	Title   []Fragment `json:"title"`
+	Snippet []Fragment `json:"snippet"`
+}
+
+// SpellingVariants lists the other spellings of a word to search for too.
+type SpellingVariants interface {
+	Variants(word string) []string
+}
+
+// SearchQuery is what to search stories for, and how.
+type SearchQuery struct {
+	Text     string
+	Fuzzy    bool             // match similar spellings by trigram similarity instead of whole words
+	Variants SpellingVariants // other spellings to accept for each word of a full-text search; may be nil
+}
+
+// Search returns one page of the published stories matching the query, best
+// matches first.
+//
+// A full-text search needs every word to appear, in any form of the word
+// ("tell" finds "telling") or any of its spelling variants. Quoted words must
+// appear together as a phrase, a trailing * matches any word starting with what
+// comes before it, a leading - excludes stories with the word, and OR between
+// words accepts either.
+//
+// A fuzzy search ignores that syntax and ranks stories by how closely their
+// title or some stretch of their content resembles the words searched for, so
+// it still finds stories when the words are spelled differently.
+func (m *StoryModel) Search(q SearchQuery, filters Filters) ([]*SearchResult, Metadata, error) {
+	parsed := parseSearch(q.Text)
+	if q.Fuzzy {
+		return m.fuzzySearch(strings.Join(parsed.words(false), " "), filters)
+	}
+	return m.fullTextSearch(parsed.tsquery(q.Variants), filters)
+}
+
+// fullTextSearch finds stories matching a tsquery, highlighting the matches with ts_headline.
+func (m *StoryModel) fullTextSearch(tsquery string, filters Filters) ([]*SearchResult, Metadata, error) {
+	if tsquery == "" {
+		return nil, Metadata{}, ErrBlankSearch
+	}
+
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `,
+			ts_rank_cd(stories.search_vector, query) AS rank,
+			ts_headline('english', stories.title, query, $4),
+			ts_headline('english', stories.content, query, $5)
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		CROSS JOIN to_tsquery('english', $1) AS query
+		WHERE ` + publicStories + ` AND stories.search_vector @@ query
+		ORDER BY rank DESC, stories.published_at DESC, stories.id DESC
+		LIMIT $2 OFFSET $3`
+
+	titleOptions := fmt.Sprintf("HighlightAll=true, StartSel=%s, StopSel=%s", highlightStart, highlightStop)
+	snippetOptions := fmt.Sprintf(`MaxFragments=2, MaxWords=30, MinWords=12, FragmentDelimiter=" … ", StartSel=%s, StopSel=%s`,
+		highlightStart, highlightStop)
+
+	rows, err := m.DB.Query(query, tsquery, filters.limit(), filters.offset(), titleOptions, snippetOptions)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanSearchResults(rows, filters, splitHighlights)
+}
+
+// fuzzySearch finds stories whose title or content resembles text, using the
+// trigram indexes. There is no highlighting: the snippet is the start of the story.
+func (m *StoryModel) fuzzySearch(text string, filters Filters) ([]*SearchResult, Metadata, error) {
+	if text == "" {
+		return nil, Metadata{}, ErrBlankSearch
+	}
+
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `,
+			GREATEST(similarity(stories.title, $1), word_similarity($1, stories.title), word_similarity($1, stories.content)) AS rank,
+			stories.title,
+			stories.content
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE ` + publicStories + `
+		AND (stories.title % $1 OR $1 <% stories.title OR $1 <% stories.content)
+		ORDER BY rank DESC, stories.published_at DESC, stories.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.Query(query, text, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanSearchResults(rows, filters, func(s string) []Fragment {
+		return []Fragment{{Text: excerpt(s, snippetWords)}}
+	})
+}
+
+// scanSearchResults reads a page of search results: COUNT(*) OVER(), storyColumns,
+// the rank, and the title and snippet, which fragments breaks up for highlighting.
+func scanSearchResults(rows *sql.Rows, filters Filters, fragments func(string) []Fragment) ([]*SearchResult, Metadata, error) {
+	defer rows.Close()
+
+	totalRecords := 0
+	var results []*SearchResult
+	for rows.Next() {
+		var story Story
+		var result SearchResult
+		var title, snippet string
+		dest := append([]interface{}{&totalRecords}, storyDest(&story)...)
+		dest = append(dest, &result.Rank, &title, &snippet)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		result.Story = &story
+		result.Title = fragments(title)
+		result.Snippet = fragments(snippet)
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return results, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// snippetWords is the most words of a story shown with a fuzzy search result.
+const snippetWords = 60
+
+// excerpt cuts s down to its first n words, marking the cut with an ellipsis.
+func excerpt(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return s
+	}
+	return strings.Join(words[:n], " ") + " …"
+}
+
+// SuggestSpelling offers a corrected search for text when some of its words
+// appear in no published story but a similar word does. It returns "" when it
+// has nothing better to suggest. Words with spelling variants are taken to be
+// spelled correctly.
+func (m *StoryModel) SuggestSpelling(text string, variants SpellingVariants) (string, error) {
+	var words []string
+	for _, word := range parseSearch(text).words(true) {
+		if variants == nil || len(variants.Variants(word)) == 0 {
+			words = append(words, word)
+		}
+	}
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	query := `
+		SELECT q.word, best.word
+		FROM unnest($1::text[]) AS q(word)
+		CROSS JOIN LATERAL (
+			SELECT story_words.word
+			FROM story_words
+			WHERE story_words.word % q.word
+			ORDER BY similarity(story_words.word, q.word) DESC, story_words.ndoc DESC, story_words.word
+			LIMIT 1
+		) AS best
+		WHERE NOT EXISTS (SELECT 1 FROM story_words WHERE story_words.word = q.word)`
+
+	rows, err := m.DB.Query(query, pq.Array(words))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	corrections := map[string]string{}
+	for rows.Next() {
+		var word, correction string
+		if err := rows.Scan(&word, &correction); err != nil {
+			return "", err
+		}
+		corrections[word] = correction
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if len(corrections) == 0 {
+		return "", nil
+	}
+	return replaceWords(text, corrections), nil
+}
+
+// RefreshSearchWords rebuilds the list of words used in published stories
+// that spelling suggestions are drawn from.
+func (m *StoryModel) RefreshSearchWords() error {
+	_, err := m.DB.Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY story_words`)
+	return err
+}
+
+// replaceWords swaps the words of text found in replacements, matching them
+// as searchWords would normalise them, and leaves everything else untouched.
+func replaceWords(text string, replacements map[string]string) string {
+	var b strings.Builder
+	word := []rune{}
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		original := string(word)
+		if words := searchWords(original); len(words) == 1 && replacements[words[0]] != "" {
+			b.WriteString(replacements[words[0]])
+		} else {
+			b.WriteString(original)
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’' {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+	return b.String()
+}
+
+// splitHighlights breaks ts_headline output into plain and matched fragments.
+func splitHighlights(s string) []Fragment {
+	var fragments []Fragment
+	for s != "" {
+		start := strings.Index(s, highlightStart)
+		if start < 0 {
+			break
+		}
+		stop := strings.Index(s[start:], highlightStop)
+		if stop < 0 {
+			break
+		}
+		stop += start
+		if start > 0 {
+			fragments = append(fragments, Fragment{Text: s[:start]})
+		}
+		fragments = append(fragments, Fragment{Text: s[start+len(highlightStart) : stop], Match: true})
+		s = s[stop+len(highlightStop):]
+	}
+	if s != "" {
+		fragments = append(fragments, Fragment{Text: s})
+	}
+	return fragments
+}
+
+// searchTerm is a word or phrase from the search box: one or more words that
+// must appear in order, each optionally matched as a prefix.
+type searchTerm struct {
+	words   []string
+	prefix  []bool
+	exclude bool
+}
+
+// tsquery renders the term in Postgres tsquery syntax, accepting the spelling
+// variants of each whole word.
+func (t searchTerm) tsquery(variants SpellingVariants) string {
+	parts := make([]string, len(t.words))
+	for i, word := range t.words {
+		switch {
+		case t.prefix[i]:
+			parts[i] = word + ":*"
+		case variants != nil && len(variants.Variants(word)) > 0:
+			parts[i] = "(" + strings.Join(append([]string{word}, variants.Variants(word)...), " | ") + ")"
+		default:
+			parts[i] = word
+		}
+	}
+
+	s := strings.Join(parts, " <-> ")
+	if len(parts) > 1 {
+		s = "(" + s + ")"
+	}
+	if t.exclude {
+		s = "!" + s
+	}
+	return s
+}
+
+// parsedSearch is search box text broken into clauses. Each clause must
+// match; the terms within a clause are alternatives joined by OR.
+type parsedSearch struct {
+	clauses [][]searchTerm
+}
+
+// parseSearch breaks search box text into terms. Only letters and digits are
+// kept from words; everything else separates them, so the tsquery built from
+// the result is always valid.
+func parseSearch(text string) parsedSearch {
+	var p parsedSearch
+	joinNext := false
+
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		exclude := false
+		if strings.HasPrefix(rest, "-") {
+			exclude = true
+			rest = rest[1:]
+		}
+
+		var raw string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			raw, rest = rest[:end], rest[end:]
+			if !exclude && raw == "OR" {
+				joinNext = len(p.clauses) > 0
+				rest = strings.TrimSpace(rest)
+				continue
+			}
+		}
+		rest = strings.TrimSpace(rest)
+
+		term := searchTerm{exclude: exclude}
+		for _, field := range strings.Fields(raw) {
+			words := searchWords(field)
+			for i, word := range words {
+				term.words = append(term.words, word)
+				term.prefix = append(term.prefix, i == len(words)-1 && strings.HasSuffix(field, "*"))
+			}
+		}
+		if len(term.words) == 0 {
+			continue
+		}
+
+		if joinNext {
+			p.clauses[len(p.clauses)-1] = append(p.clauses[len(p.clauses)-1], term)
+		} else {
+			p.clauses = append(p.clauses, []searchTerm{term})
+		}
+		joinNext = false
+	}
+	return p
+}
+
+// tsquery renders the search in Postgres tsquery syntax, returning "" when
+// there is nothing to search for.
+func (p parsedSearch) tsquery(variants SpellingVariants) string {
+	parts := make([]string, 0, len(p.clauses))
+	for _, clause := range p.clauses {
+		alternatives := make([]string, len(clause))
+		for i, term := range clause {
+			alternatives[i] = term.tsquery(variants)
+		}
+		part := strings.Join(alternatives, " | ")
+		if len(alternatives) > 1 {
+			part = "(" + part + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " & ")
+}
+
+// words returns the words the search looks for, leaving out excluded ones and,
+// if wholeOnly is set, prefixes.
+func (p parsedSearch) words(wholeOnly bool) []string {
+	var words []string
+	for _, clause := range p.clauses {
+		for _, term := range clause {
+			if term.exclude {
+				continue
+			}
+			for i, word := range term.words {
+				if !wholeOnly || !term.prefix[i] {
+					words = append(words, word)
+				}
+			}
+		}
+	}
+	return words
+}
+
+// searchWords splits a word from the search box into its lower-case runs of
+// letters and digits, so "River-Mumma's" gives "river" and "mummas".
+func searchWords(s string) []string {
+	s = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(s))
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+// testVariants is a SpellingVariants backed by a map
+type testVariants map[string][]string
+
+func (v testVariants) Variants(word string) []string {
+	return v[word]
+}
+
+var kriol = testVariants{
+	"pikni":   {"pickney"},
+	"pickney": {"pikni"},
+	"dem":     {"dehm", "dom"},
+}
+
+func TestParseSearchTsquery(t *testing.T) {
+	tests := []struct {
+		text     string
+		variants SpellingVariants
+		want     string
+	}{
+		{"", nil, ""},
+		{"  !!! & | ", nil, ""},
+		{"Anansi", nil, "anansi"},
+		{"Anansi  Tiger", nil, "anansi & tiger"},
+		{`"Bredda Anansi"`, nil, "(bredda <-> anansi)"},
+		{`"unclosed quote`, nil, "(unclosed <-> quote)"},
+		{"tell*", nil, "tell:*"},
+		{`"bredda anan*"`, nil, "(bredda <-> anan:*)"},
+		{"-tiger anansi", nil, "!tiger & anansi"},
+		{`-"bad luck" anansi`, nil, "!(bad <-> luck) & anansi"},
+		{"anansi OR tiger OR dog", nil, "(anansi | tiger | dog)"},
+		{"anansi OR tiger rat", nil, "(anansi | tiger) & rat"},
+		{"OR anansi OR", nil, "anansi"},
+		{"anansi or tiger", nil, "anansi & or & tiger"},
+		{"River-Mumma's", nil, "(river <-> mummas)"},
+		{"a&b | c:* !d (e)", nil, "(a <-> b) & c:* & d & e"},
+		{"pikni", kriol, "(pikni | pickney)"},
+		{"pikni*", kriol, "pikni:*"},
+		{`"dem pikni"`, kriol, "((dem | dehm | dom) <-> (pikni | pickney))"},
+		{"-dem", kriol, "!(dem | dehm | dom)"},
+		{"Pikni OR tiger", kriol, "((pikni | pickney) | tiger)"},
+	}
+
+	for _, tt := range tests {
+		if got := parseSearch(tt.text).tsquery(tt.variants); got != tt.want {
+			t.Errorf("parseSearch(%q).tsquery = %q; want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearchWords(t *testing.T) {
+	tests := []struct {
+		text      string
+		wholeOnly bool
+		want      []string
+	}{
+		{"anansi -tiger tell*", false, []string{"anansi", "tell"}},
+		{"anansi -tiger tell*", true, []string{"anansi"}},
+		{`"Bredda Anansi" OR Tiger's`, false, []string{"bredda", "anansi", "tigers"}},
+		{"-only -excluded", false, nil},
+	}
+
+	for _, tt := range tests {
+		if got := parseSearch(tt.text).words(tt.wholeOnly); !slices.Equal(got, tt.want) {
+			t.Errorf("parseSearch(%q).words(%t) = %q; want %q", tt.text, tt.wholeOnly, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTermTsquery(t *testing.T) {
+	tests := []struct {
+		name     string
+		term     searchTerm
+		variants SpellingVariants
+		want     string
+	}{
+		{"word", searchTerm{words: []string{"anansi"}, prefix: []bool{false}}, nil, "anansi"},
+		{"prefix", searchTerm{words: []string{"anan"}, prefix: []bool{true}}, nil, "anan:*"},
+		{"excluded", searchTerm{words: []string{"tiger"}, prefix: []bool{false}, exclude: true}, nil, "!tiger"},
+		{"phrase", searchTerm{words: []string{"bredda", "anansi"}, prefix: []bool{false, false}}, nil, "(bredda <-> anansi)"},
+		{"excluded phrase", searchTerm{words: []string{"bad", "luck"}, prefix: []bool{false, true}, exclude: true}, nil, "!(bad <-> luck:*)"},
+		{"variants", searchTerm{words: []string{"dem"}, prefix: []bool{false}}, kriol, "(dem | dehm | dom)"},
+		{"no variants", searchTerm{words: []string{"tiger"}, prefix: []bool{false}}, kriol, "tiger"},
+		{"prefix skips variants", searchTerm{words: []string{"dem"}, prefix: []bool{true}}, kriol, "dem:*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.term.tsquery(tt.variants); got != tt.want {
+				t.Errorf("tsquery = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceWords(t *testing.T) {
+	corrections := map[string]string{"tigr": "tiger", "mummas": "mumma's", "anansy": "anansi"}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"tigr", "tiger"},
+		{"TIGR", "tiger"},
+		{"Bredda Anansy an Tigr!", "Bredda anansi an tiger!"},
+		{"River-Mummas", "River-mumma's"},
+		{"river mumma’s", "river mumma's"},
+		{`"tigr" OR -anansy*`, `"tiger" OR -anansi*`},
+		{"tigrs tig", "tigrs tig"},
+		{"  spaced   out  ", "  spaced   out  "},
+	}
+
+	for _, tt := range tests {
+		if got := replaceWords(tt.text, corrections); got != tt.want {
+			t.Errorf("replaceWords(%q) = %q; want %q", tt.text, got, tt.want)
+		}
+	}
+}
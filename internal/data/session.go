@@ -0,0 +1,17 @@
+package data
+
+import "time"
+
+// Session is a server-side login session. The browser only holds a signed
+// random token; the database stores its SHA-256 hash alongside the encoded
+// session values and some details about the device that created it.
+type Session struct {
+	ID         int64
+	UserID     *int
+	Data       string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
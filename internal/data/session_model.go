@@ -0,0 +1,158 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SessionModel wraps a sql.DB connection pool for working with server-side sessions
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// GetByToken fetches an unexpired session by its plaintext token
+func (m *SessionModel) GetByToken(token string) (*Session, error) {
+	query := `
+		SELECT id, user_id, data, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE token_hash = $1 AND expires_at > NOW()`
+
+	var s Session
+	err := m.DB.QueryRow(query, hashToken(token)).Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Data,
+		&s.UserAgent,
+		&s.IP,
+		&s.CreatedAt,
+		&s.LastSeenAt,
+		&s.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Insert stores a new session under the hash of its token
+func (m *SessionModel) Insert(token string, s *Session) error {
+	query := `
+		INSERT INTO sessions (token_hash, user_id, data, user_agent, ip, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, last_seen_at`
+
+	return m.DB.QueryRow(query, hashToken(token), s.UserID, s.Data, s.UserAgent, s.IP, s.ExpiresAt).Scan(
+		&s.ID,
+		&s.CreatedAt,
+		&s.LastSeenAt,
+	)
+}
+
+// Update replaces the values, owner and expiry of an existing session
+func (m *SessionModel) Update(token string, s *Session) error {
+	query := `
+		UPDATE sessions
+		SET user_id = $1, data = $2, expires_at = $3, last_seen_at = NOW()
+		WHERE token_hash = $4`
+
+	result, err := m.DB.Exec(query, s.UserID, s.Data, s.ExpiresAt, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Touch records activity on a session from the given IP address
+func (m *SessionModel) Touch(id int64, ip string) error {
+	_, err := m.DB.Exec(`UPDATE sessions SET last_seen_at = NOW(), ip = $1 WHERE id = $2`, ip, id)
+	return err
+}
+
+// GetAllForUser lists a user's unexpired sessions, most recently active first
+func (m *SessionModel) GetAllForUser(userID int) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND expires_at > NOW()
+		ORDER BY last_seen_at DESC`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var s Session
+		err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.UserAgent,
+			&s.IP,
+			&s.CreatedAt,
+			&s.LastSeenAt,
+			&s.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteByToken removes the session identified by its plaintext token
+func (m *SessionModel) DeleteByToken(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM sessions WHERE token_hash = $1`, hashToken(token))
+	return err
+}
+
+// Delete revokes a session by its ID if it belongs to the given user ID
+func (m *SessionModel) Delete(id int64, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteAllForUser revokes every session of the user except the one identified
+// by exceptToken (pass an empty string to revoke them all)
+func (m *SessionModel) DeleteAllForUser(userID int, exceptToken string) error {
+	query := `
+		DELETE FROM sessions
+		WHERE user_id = $1 AND token_hash <> $2`
+
+	_, err := m.DB.Exec(query, userID, hashToken(exceptToken))
+	return err
+}
+
+// DeleteExpired removes sessions whose expiry has passed and returns how many were removed
+func (m *SessionModel) DeleteExpired() (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM sessions WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -1,13 +1,75 @@
 package data
 
-import "time"
+import (
+	"strconv"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/slug"
+)
+
+// Story statuses, from least to most visible
+const (
+	StatusDraft     = "draft"     // a work in progress, seen only by its author
+	StatusScheduled = "scheduled" // seen only by its author until ScheduledAt, then published
+	StatusPrivate   = "private"   // finished, but seen only by its author
+	StatusUnlisted  = "unlisted"  // seen by anyone with the link, but left out of listings
+	StatusPublished = "published" // listed for everyone
+)
+
+// StoryStatuses lists every status, least visible first
+var StoryStatuses = []string{StatusDraft, StatusScheduled, StatusPrivate, StatusUnlisted, StatusPublished}
+
+// ValidStoryStatus reports whether status is one of StoryStatuses
+func ValidStoryStatus(status string) bool {
+	for _, s := range StoryStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
 
 type Story struct {
-	ID        int
-	Title     string
-	Content   string
-	UserID    int
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserEmail string
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	UserID      int        `json:"user_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	PublishedAt *time.Time `json:"published_at"` // when the story was first shared, if it has been
+	ScheduledAt *time.Time `json:"scheduled_at"` // when a scheduled story will be published
+	Version     int        `json:"version"`      // incremented by every edit
+	DeletedAt   *time.Time `json:"-"`            // set while the story is soft-deleted
+	HiddenAt    *time.Time `json:"-"`            // set while the story is hidden by moderation
+	UserEmail   string     `json:"user_email"`
+	Tags        []string   `json:"tags"` // normalised tag names, alphabetical
+
+	CommentsLockedAt *time.Time `json:"comments_locked_at"` // set while the author has closed the story to new comments
+}
+
+// IsShared reports whether the author has let other people see the story
+func (s *Story) IsShared() bool {
+	return s.Status == StatusUnlisted || s.Status == StatusPublished
+}
+
+// Slug is the readable part of the story's URL, made from its title
+func (s *Story) Slug() string {
+	return slug.Make(s.Title)
+}
+
+// Path is the story's canonical URL path, /story/{id}/{slug}. Links with an
+// out-of-date slug still work and are redirected here.
+func (s *Story) Path() string {
+	return "/story/" + strconv.Itoa(s.ID) + "/" + s.Slug()
+}
+
+// CommentsLocked reports whether the story is closed to new comments
+func (s *Story) CommentsLocked() bool {
+	return s.CommentsLockedAt != nil
+}
+
+// IsPublic reports whether anyone may read the story: it is shared and not hidden by moderators
+func (s *Story) IsPublic() bool {
+	return s.IsShared() && s.HiddenAt == nil
 }
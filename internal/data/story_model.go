@@ -3,8 +3,15 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// ErrEditConflict is returned by Update when the story has been edited since the version being saved was loaded.
+var ErrEditConflict = errors.New("edit conflict")
+
 // StoryModel is a struct that holds a reference to the database connection (DB).
 // It contains methods for interacting with the 'stories' table in the database.
 type StoryModel struct {
@@ -12,41 +19,101 @@ type StoryModel struct {
 }
 
 // Insert inserts a new story into the 'stories' table and returns the story's details.
+// The text is also saved as the story's first revision, and its tags are attached.
 func (m *StoryModel) Insert(story *Story) error {
-	// The query to insert a new story into the 'stories' table
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The query to insert a new story into the 'stories' table; shared stories are published straight away
+	// and scheduled ones keep their publication time
 	query := `
-		INSERT INTO stories (title, content, user_id)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at`
-	// Executes the query and returns the inserted story's ID, created_at, and updated_at values
-	return m.DB.QueryRow(query, story.Title, story.Content, story.UserID).Scan(
+		INSERT INTO stories (title, content, user_id, status, published_at, scheduled_at)
+		VALUES ($1, $2, $3, $4, CASE WHEN $4 IN ('unlisted', 'published') THEN NOW() END,
+			CASE WHEN $4 = 'scheduled' THEN $5::timestamptz END)
+		RETURNING id, created_at, updated_at, published_at, scheduled_at, version`
+	// Executes the query and returns the inserted story's ID, timestamps and version values
+	err = tx.QueryRow(query, story.Title, story.Content, story.UserID, story.Status, story.ScheduledAt).Scan(
 		&story.ID,
 		&story.CreatedAt,
 		&story.UpdatedAt,
+		&story.PublishedAt,
+		&story.ScheduledAt,
+		&story.Version,
 	)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, story, story.UserID, nil)
+	if err != nil {
+		return err
+	}
+	err = setStoryTags(tx, story.ID, story.Tags)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// storyColumns is the column list every query that loads stories selects,
+// in the order expected by storyDest. Queries must join users for the author's email.
+const storyColumns = `stories.id, stories.title, stories.content, stories.user_id, stories.status,
+	stories.created_at, stories.updated_at, stories.published_at, stories.scheduled_at, stories.version,
+	stories.deleted_at, stories.hidden_at, stories.comments_locked_at, users.email, ` + storyTagsColumn
+
+// storyDest returns the scan destinations matching storyColumns
+func storyDest(story *Story) []interface{} {
+	return []interface{}{
+		&story.ID,
+		&story.Title,
+		&story.Content,
+		&story.UserID,
+		&story.Status,
+		&story.CreatedAt,
+		&story.UpdatedAt,
+		&story.PublishedAt,
+		&story.ScheduledAt,
+		&story.Version,
+		&story.DeletedAt,
+		&story.HiddenAt,
+		&story.CommentsLockedAt,
+		&story.UserEmail,
+		pq.Array(&story.Tags),
+	}
+}
+
+// scanStories reads every row of a query selecting storyColumns
+func scanStories(rows *sql.Rows) ([]*Story, error) {
+	defer rows.Close()
+
+	var stories []*Story
+	// Iterate through the returned rows and append the story details to the stories slice
+	for rows.Next() {
+		var story Story
+		if err := rows.Scan(storyDest(&story)...); err != nil {
+			return nil, err
+		}
+		stories = append(stories, &story)
+	}
+	return stories, rows.Err()
 }
 
 // Get retrieves a story by its ID from the database and returns the story's details.
+// Deleted stories are not found; unshared and hidden ones are, so callers must check who may see it.
 func (m *StoryModel) Get(id int) (*Story, error) {
 	// The query to retrieve a story by its ID
 	query := `
-		SELECT stories.id, stories.title, stories.content, stories.user_id,
-			   stories.created_at, stories.updated_at, users.email
+		SELECT ` + storyColumns + `
 		FROM stories
 		INNER JOIN users ON stories.user_id = users.id
-		WHERE stories.id = $1`
+		WHERE stories.id = $1 AND stories.deleted_at IS NULL`
 
 	var story Story
 	// Executes the query to fetch the story details and scan the results into the 'story' struct
-	err := m.DB.QueryRow(query, id).Scan(
-		&story.ID,
-		&story.Title,
-		&story.Content,
-		&story.UserID,
-		&story.CreatedAt,
-		&story.UpdatedAt,
-		&story.UserEmail,
-	)
+	err := m.DB.QueryRow(query, id).Scan(storyDest(&story)...)
 	// If no rows are returned, return a custom error (ErrRecordNotFound)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -57,114 +124,242 @@ func (m *StoryModel) Get(id int) (*Story, error) {
 	return &story, nil
 }
 
-// GetLatest retrieves the latest 'limit' number of stories from the database.
+// GetLatest retrieves the latest 'limit' number of published stories from the database.
+// Hidden stories are left out.
 func (m *StoryModel) GetLatest(limit int) ([]*Story, error) {
-	// The query to retrieve the latest stories, ordered by creation date (descending).
+	// The query to retrieve the latest stories, ordered by publication date (descending).
 	query := `
-		SELECT stories.id, stories.title, LEFT(stories.content, 500) as excerpt,
-			   stories.created_at, stories.updated_at, users.email
+		SELECT ` + storyColumns + `
 		FROM stories
 		INNER JOIN users ON stories.user_id = users.id
-		ORDER BY stories.created_at DESC
+		WHERE ` + publicStories + `
+		ORDER BY stories.published_at DESC
 		LIMIT $1`
 	// Executes the query to fetch the latest stories
 	rows, err := m.DB.Query(query, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanStories(rows)
+}
 
-	var stories []*Story
-	// Iterate through the returned rows and append the story details to the stories slice
-	for rows.Next() {
-		var story Story
-		err := rows.Scan(
-			&story.ID,
-			&story.Title,
-			&story.Content,
-			&story.CreatedAt,
-			&story.UpdatedAt,
-			&story.UserEmail,
-		)
+// ListPublished returns one page of the published stories, newest first. Pages are
+// found by keyset on (published_at, id) rather than OFFSET, so they cost the same
+// however deep the reader goes and do not shift when new stories are published.
+// Hidden stories are left out.
+//
+// The key is published_at, not created_at: stories written as drafts or
+// scheduled for later go out long after they were created, and listing them by
+// created_at would put them out of order and behind pages readers have already seen.
+func (m *StoryModel) ListPublished(filters CursorFilters) ([]*Story, CursorMetadata, error) {
+	metadata := CursorMetadata{PageSize: filters.PageSize}
+
+	// Going back a page reads the newer stories oldest first and reverses them afterwards
+	backwards := filters.Before != ""
+	encoded, comparison, direction := filters.After, "<", "DESC"
+	if backwards {
+		encoded, comparison, direction = filters.Before, ">", "ASC"
+	}
+
+	// One story more than a page is fetched to tell whether there is another page beyond it
+	condition := publicStories
+	args := []interface{}{filters.PageSize + 1}
+	if encoded != "" {
+		cursor, err := DecodeCursor(encoded)
 		if err != nil {
-			return nil, err
+			return nil, metadata, err
 		}
-		stories = append(stories, &story)
+		condition += ` AND (stories.published_at, stories.id) ` + comparison + ` ($2, $3)`
+		args = append(args, cursor.Time, cursor.ID)
 	}
 
-	return stories, nil
+	query := `
+		SELECT ` + storyColumns + `
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE ` + condition + `
+		ORDER BY stories.published_at ` + direction + `, stories.id ` + direction + `
+		LIMIT $1`
+
+	rows, err := m.DB.Query(query, args...)
+	if err != nil {
+		return nil, metadata, err
+	}
+	stories, err := scanStories(rows)
+	if err != nil {
+		return nil, metadata, err
+	}
+
+	more := len(stories) > filters.PageSize
+	if more {
+		stories = stories[:filters.PageSize]
+	}
+	if backwards {
+		slices.Reverse(stories)
+	}
+	if len(stories) == 0 {
+		return stories, metadata, nil
+	}
+
+	// Coming from a cursor means there are stories on the side we came from
+	if (backwards && more) || (!backwards && encoded != "") {
+		metadata.PrevCursor = publishedCursor(stories[0])
+	}
+	if (!backwards && more) || backwards {
+		metadata.NextCursor = publishedCursor(stories[len(stories)-1])
+	}
+	return stories, metadata, nil
 }
-// GetAllPaginated retrieves all stories in a paginated manner based on the page number and page size.
-func (m *StoryModel) GetAllPaginated(page, pageSize int) ([]*Story, error) {
-	offset := (page - 1) * pageSize
-	// The query to retrieve paginated stories, ordered by creation date (descending)
+
+// publishedCursor returns the position of a published story in ListPublished
+func publishedCursor(story *Story) string {
+	return Cursor{Time: *story.PublishedAt, ID: story.ID}.Encode()
+}
+
+// publicStories is the condition for stories shown in public listings
+const publicStories = `stories.status = 'published' AND stories.deleted_at IS NULL AND stories.hidden_at IS NULL`
+
+// ListByTag returns one page of the published stories carrying a tag, newest first.
+func (m *StoryModel) ListByTag(tag string, filters Filters) ([]*Story, Metadata, error) {
 	query := `
-		SELECT stories.id, stories.title, LEFT(stories.content, 500) as excerpt,
-			   stories.created_at, stories.updated_at, users.email
+		SELECT COUNT(*) OVER(), ` + storyColumns + `
 		FROM stories
 		INNER JOIN users ON stories.user_id = users.id
-		ORDER BY stories.created_at DESC
-		LIMIT $1 OFFSET $2`
-// Executes the query to fetch the paginated stories
-	rows, err := m.DB.Query(query, pageSize, offset)
+		WHERE ` + publicStories + `
+		AND EXISTS (
+			SELECT 1 FROM story_tags
+			INNER JOIN tags ON tags.id = story_tags.tag_id
+			WHERE story_tags.story_id = stories.id AND tags.name = $1)
+		ORDER BY stories.published_at DESC, stories.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.Query(query, tag, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanStoryPage(rows, filters)
+}
+
+// ListForUser returns one page of an author's stories with the given status,
+// most recently updated first. Deleted stories are left out.
+func (m *StoryModel) ListForUser(userID int, status string, filters Filters) ([]*Story, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE stories.user_id = $1 AND stories.status = $2 AND stories.deleted_at IS NULL
+		ORDER BY stories.updated_at DESC, stories.id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, userID, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanStoryPage(rows, filters)
+}
+
+// CountByStatusForUser returns how many of an author's stories have each status.
+// Statuses with no stories are present with a count of zero.
+func (m *StoryModel) CountByStatusForUser(userID int) (map[string]int, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM stories
+		WHERE user_id = $1 AND deleted_at IS NULL
+		GROUP BY status`
+
+	rows, err := m.DB.Query(query, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var stories []*Story
-	// Iterate through the returned rows and append the story details to the stories slice
+	counts := make(map[string]int, len(StoryStatuses))
+	for _, status := range StoryStatuses {
+		counts[status] = 0
+	}
 	for rows.Next() {
-		var story Story
-		err := rows.Scan(
-			&story.ID,
-			&story.Title,
-			&story.Content,
-			&story.CreatedAt,
-			&story.UpdatedAt,
-			&story.UserEmail,
-		)
-		if err != nil {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
 			return nil, err
 		}
-		stories = append(stories, &story)
+		counts[status] = count
 	}
-
-	return stories, nil
+	return counts, rows.Err()
 }
-// GetTotalCount retrieves the total number of stories in the 'stories' table.
-func (m *StoryModel) GetTotalCount() (int, error) {
-	var count int
-		// The query to count the number of stories in the 'stories' table
-	err := m.DB.QueryRow("SELECT COUNT(*) FROM stories").Scan(&count)
-	return count, err
+
+// Update updates a story's title, content, status and tags in the 'stories' table and saves
+// the new text as a revision made by editorID. story.Version must be the version
+// the edit started from; if the story has changed since, Update returns
+// ErrEditConflict. On success story.Version is the new version.
+// Callers are responsible for checking the user may edit the story.
+func (m *StoryModel) Update(story *Story, editorID int) error {
+	return m.update(story, editorID, nil)
 }
-// Update updates a story's title and content in the 'stories' table.
-func (m *StoryModel) Update(story *Story) error {
-	// The query to update the story's title, content, and updated_at timestamp
+
+// update saves a story's new text, its tags and its revision in one transaction.
+// restoredFrom is the revision number being rolled back to, if any.
+func (m *StoryModel) update(story *Story, editorID int, restoredFrom *int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The query to update the story's title, content, status, version and updated_at timestamp,
+	// as long as nobody else has saved a new version in the meantime. published_at is
+	// set the first time the story is shared and kept from then on.
 	query := `
 		UPDATE stories
-		SET title = $1, content = $2, updated_at = NOW()
-		WHERE id = $3 AND user_id = $4
-		RETURNING updated_at`
-		// Executes the query to update the story and retrieve the updated timestamp
-	err := m.DB.QueryRow(query,
+		SET title = $1, content = $2, status = $3, updated_at = NOW(), version = version + 1,
+			published_at = COALESCE(published_at, CASE WHEN $3 IN ('unlisted', 'published') THEN NOW() END),
+			scheduled_at = CASE WHEN $3 = 'scheduled' THEN $4::timestamptz END
+		WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING updated_at, published_at, scheduled_at, version`
+		// Executes the query to update the story and retrieve the updated timestamps and version
+	err = tx.QueryRow(query,
 		story.Title,
 		story.Content,
+		story.Status,
+		story.ScheduledAt,
 		story.ID,
-		story.UserID,
-	).Scan(&story.UpdatedAt)
+		story.Version,
+	).Scan(&story.UpdatedAt, &story.PublishedAt, &story.ScheduledAt, &story.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the story is gone or its version moved on
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM stories WHERE id = $1 AND deleted_at IS NULL)`, story.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrEditConflict
+		}
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	err = insertRevision(tx, story, editorID, restoredFrom)
+	if err != nil {
+		return err
+	}
+	err = setStoryTags(tx, story.ID, story.Tags)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
-// Delete deletes a story by its ID if it belongs to the given user ID.
-func (m *StoryModel) Delete(id int, userID int) error {
-	// The query to delete a story based on its ID and the user ID
+// Delete permanently deletes a story by its ID, whether or not it was soft-deleted.
+// Callers are responsible for checking the user may delete the story.
+func (m *StoryModel) Delete(id int) error {
+	// The query to delete a story based on its ID
 	query := `
 		DELETE FROM stories
-		WHERE id = $1 AND user_id = $2`
+		WHERE id = $1`
 // Executes the query to delete the story
-	result, err := m.DB.Exec(query, id, userID)
+	result, err := m.DB.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -180,3 +375,207 @@ func (m *StoryModel) Delete(id int, userID int) error {
 
 	return nil
 }
+
+// SoftDelete marks a story as deleted, hiding it everywhere while keeping the row so it can be restored.
+func (m *StoryModel) SoftDelete(id int) error {
+	result, err := m.DB.Exec(`UPDATE stories SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// Restore brings back a soft-deleted story.
+func (m *StoryModel) Restore(id int) error {
+	result, err := m.DB.Exec(`UPDATE stories SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// GetDeleted retrieves a story from the trash. Only soft-deleted stories are found.
+func (m *StoryModel) GetDeleted(id int) (*Story, error) {
+	query := `
+		SELECT ` + storyColumns + `
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE stories.id = $1 AND stories.deleted_at IS NOT NULL`
+
+	var story Story
+	err := m.DB.QueryRow(query, id).Scan(storyDest(&story)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &story, nil
+}
+
+// ListDeletedForUser returns one page of a user's trash, most recently deleted first.
+func (m *StoryModel) ListDeletedForUser(userID int, filters Filters) ([]*Story, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE stories.user_id = $1 AND stories.deleted_at IS NOT NULL
+		ORDER BY stories.deleted_at DESC, stories.id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.Query(query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanStoryPage(rows, filters)
+}
+
+// Purge permanently deletes a story from the trash. Stories that are not in the trash are not found.
+func (m *StoryModel) Purge(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM stories WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// PurgeDeleted permanently deletes stories that were moved to the trash before
+// cutoff and returns how many were removed.
+func (m *StoryModel) PurgeDeleted(cutoff time.Time) (int64, error) {
+	result, err := m.DB.Exec(`DELETE FROM stories WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PublishDue publishes every scheduled story whose time has come by now and
+// returns them. Their version moves on, so an edit started before the story
+// went out is reported as a conflict instead of undoing the publication.
+func (m *StoryModel) PublishDue(now time.Time) ([]*Story, error) {
+	query := `
+		WITH published AS (
+			UPDATE stories
+			SET status = 'published', published_at = COALESCE(published_at, scheduled_at),
+				scheduled_at = NULL, version = version + 1
+			WHERE status = 'scheduled' AND scheduled_at <= $1 AND deleted_at IS NULL
+			RETURNING *
+		)
+		SELECT ` + storyColumns + `
+		FROM published AS stories
+		INNER JOIN users ON stories.user_id = users.id
+		ORDER BY stories.published_at, stories.id`
+
+	rows, err := m.DB.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	return scanStories(rows)
+}
+
+// Hide keeps a story out of public listings while moderators review it.
+func (m *StoryModel) Hide(id int) error {
+	result, err := m.DB.Exec(`UPDATE stories SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// Unhide returns a hidden story to public listings.
+func (m *StoryModel) Unhide(id int) error {
+	result, err := m.DB.Exec(`UPDATE stories SET hidden_at = NULL WHERE id = $1 AND hidden_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// SetCommentsLocked stops or allows new comments on a story.
+func (m *StoryModel) SetCommentsLocked(id int, locked bool) error {
+	query := `
+		UPDATE stories
+		SET comments_locked_at = CASE WHEN $2 THEN COALESCE(comments_locked_at, NOW()) END
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := m.DB.Exec(query, id, locked)
+	if err != nil {
+		return err
+	}
+	return expectRow(result)
+}
+
+// storySortColumns maps the sort keys accepted by List to columns.
+var storySortColumns = map[string]string{
+	"title":      "stories.title",
+	"author":     "users.email",
+	"created_at": "stories.created_at",
+	"updated_at": "stories.updated_at",
+}
+
+// Story states accepted by List.
+const (
+	StoryStateActive  = "active"
+	StoryStateDeleted = "deleted"
+	StoryStateAll     = "all"
+)
+
+// List returns one page of stories in the given state (see the StoryState constants)
+// whose title, content or author's email contain the search text, for the admin area.
+func (m *StoryModel) List(filters Filters, state string) ([]*Story, Metadata, error) {
+	condition := "stories.deleted_at IS NULL"
+	switch state {
+	case StoryStateDeleted:
+		condition = "stories.deleted_at IS NOT NULL"
+	case StoryStateAll:
+		condition = "TRUE"
+	}
+
+	query := `
+		SELECT COUNT(*) OVER(), ` + storyColumns + `
+		FROM stories
+		INNER JOIN users ON stories.user_id = users.id
+		WHERE ` + condition + `
+		AND ($1 = '' OR stories.title ILIKE $2 OR stories.content ILIKE $2 OR users.email ILIKE $2)
+		ORDER BY ` + filters.orderBy(storySortColumns, "stories.created_at DESC") + `, stories.id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, filters.Search, filters.searchPattern(), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return scanStoryPage(rows, filters)
+}
+
+// scanStoryPage reads every row of a query selecting COUNT(*) OVER() followed by storyColumns
+func scanStoryPage(rows *sql.Rows, filters Filters) ([]*Story, Metadata, error) {
+	defer rows.Close()
+
+	totalRecords := 0
+	var stories []*Story
+	for rows.Next() {
+		var story Story
+		dest := append([]interface{}{&totalRecords}, storyDest(&story)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		stories = append(stories, &story)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return stories, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// expectRow returns ErrRecordNotFound if a statement affected no rows.
+func expectRow(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
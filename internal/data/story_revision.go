@@ -0,0 +1,108 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// StoryRevision is one saved version of a story's title and content
+type StoryRevision struct {
+	ID           int64
+	StoryID      int
+	Revision     int // numbered from 1 for each story
+	Title        string
+	Content      string
+	EditorID     *int // nil once the editor's account is deleted
+	EditorEmail  string
+	RestoredFrom *int // the revision this one rolled back to, if any
+	CreatedAt    time.Time
+}
+
+// insertRevision records the story's current text as its next revision.
+// The row lock on the story, taken by the caller's INSERT or UPDATE, keeps
+// revision numbers from racing.
+func insertRevision(tx *sql.Tx, story *Story, editorID int, restoredFrom *int) error {
+	query := `
+		INSERT INTO story_revisions (story_id, revision, title, content, editor_id, restored_from, created_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6
+		FROM story_revisions
+		WHERE story_id = $1`
+
+	_, err := tx.Exec(query, story.ID, story.Title, story.Content, editorID, restoredFrom, story.UpdatedAt)
+	return err
+}
+
+// revisionColumns is the column list every query that loads revisions selects,
+// in the order expected by revisionDest. Queries must left join users for the editor's email.
+const revisionColumns = `story_revisions.id, story_revisions.story_id, story_revisions.revision,
+	story_revisions.title, story_revisions.content, story_revisions.editor_id,
+	COALESCE(users.email, ''), story_revisions.restored_from, story_revisions.created_at`
+
+// revisionDest returns the scan destinations matching revisionColumns
+func revisionDest(rev *StoryRevision) []interface{} {
+	return []interface{}{
+		&rev.ID,
+		&rev.StoryID,
+		&rev.Revision,
+		&rev.Title,
+		&rev.Content,
+		&rev.EditorID,
+		&rev.EditorEmail,
+		&rev.RestoredFrom,
+		&rev.CreatedAt,
+	}
+}
+
+// Revisions returns every revision of a story, newest first
+func (m *StoryModel) Revisions(storyID int) ([]*StoryRevision, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM story_revisions
+		LEFT JOIN users ON story_revisions.editor_id = users.id
+		WHERE story_revisions.story_id = $1
+		ORDER BY story_revisions.revision DESC`
+
+	rows, err := m.DB.Query(query, storyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*StoryRevision
+	for rows.Next() {
+		var rev StoryRevision
+		if err := rows.Scan(revisionDest(&rev)...); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns one revision of a story by its number
+func (m *StoryModel) GetRevision(storyID, revision int) (*StoryRevision, error) {
+	query := `
+		SELECT ` + revisionColumns + `
+		FROM story_revisions
+		LEFT JOIN users ON story_revisions.editor_id = users.id
+		WHERE story_revisions.story_id = $1 AND story_revisions.revision = $2`
+
+	var rev StoryRevision
+	err := m.DB.QueryRow(query, storyID, revision).Scan(revisionDest(&rev)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// RestoreRevision rolls a story back to the text of an earlier revision. The
+// rollback is saved as a new revision, so no history is lost.
+func (m *StoryModel) RestoreRevision(story *Story, rev *StoryRevision, editorID int) error {
+	story.Title = rev.Title
+	story.Content = rev.Content
+	return m.update(story, editorID, &rev.Revision)
+}
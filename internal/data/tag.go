@@ -0,0 +1,100 @@
+package data
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on the tags of a story
+const (
+	MaxStoryTags = 5
+	MinTagLength = 2
+	MaxTagLength = 30
+)
+
+// TagWeights is the number of sizes in the tag cloud; weights run from 1 to TagWeights.
+const TagWeights = 5
+
+// TagCount is a tag with the number of published stories using it.
+type TagCount struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Weight int    `json:"-"` // size in the tag cloud, from 1 for the least used to TagWeights
+}
+
+// NormalizeTag turns free text into a tag name: lower-case letters and digits,
+// with every run of anything else made a single hyphen, so " Folk Tales! "
+// becomes "folk-tales". Apostrophes are dropped so "Anansi's" stays one word.
+// It returns "" when no letters or digits are left.
+func NormalizeTag(s string) string {
+	var b strings.Builder
+	gap := false
+	for _, r := range strings.ToLower(s) {
+		if r == '\'' || r == '’' {
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			gap = true
+			continue
+		}
+		if gap && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		gap = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ParseTags splits comma-separated input into normalised tag names.
+func ParseTags(input string) []string {
+	return NormalizeTags(strings.Split(input, ","))
+}
+
+// NormalizeTags normalises each of names, dropping blanks and duplicates while
+// keeping the order they were given in.
+func NormalizeTags(names []string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// ValidTagLength reports whether a normalised tag is neither too short nor too long.
+func ValidTagLength(tag string) bool {
+	n := utf8.RuneCountInString(tag)
+	return n >= MinTagLength && n <= MaxTagLength
+}
+
+// weighTags sets the Weight of each tag on a logarithmic scale between the
+// least and most used, so a few very popular tags do not dwarf the rest.
+func weighTags(tags []*TagCount) {
+	if len(tags) == 0 {
+		return
+	}
+
+	least, most := tags[0].Count, tags[0].Count
+	for _, tag := range tags {
+		least = min(least, tag.Count)
+		most = max(most, tag.Count)
+	}
+
+	spread := math.Log(float64(most)) - math.Log(float64(least))
+	for _, tag := range tags {
+		if spread == 0 {
+			tag.Weight = (TagWeights + 1) / 2
+			continue
+		}
+		scaled := (math.Log(float64(tag.Count)) - math.Log(float64(least))) / spread
+		tag.Weight = 1 + int(math.Round(scaled*float64(TagWeights-1)))
+	}
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// TagModel wraps a sql.DB connection pool for working with tags
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Cloud returns the limit most used tags among published stories, in
+// alphabetical order and weighted for display as a tag cloud.
+func (m *TagModel) Cloud(limit int) ([]*TagCount, error) {
+	query := `
+		SELECT name, count FROM (
+			SELECT tags.name, COUNT(*) AS count
+			FROM tags
+			INNER JOIN story_tags ON story_tags.tag_id = tags.id
+			INNER JOIN stories ON stories.id = story_tags.story_id
+			WHERE ` + publicStories + `
+			GROUP BY tags.name
+			ORDER BY count DESC, tags.name
+			LIMIT $1
+		) AS top
+		ORDER BY name`
+
+	rows, err := m.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*TagCount
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	weighTags(tags)
+	return tags, nil
+}
+
+// storyTagsColumn selects a story's tag names in alphabetical order, for storyColumns
+const storyTagsColumn = `ARRAY(
+		SELECT tags.name FROM story_tags
+		INNER JOIN tags ON tags.id = story_tags.tag_id
+		WHERE story_tags.story_id = stories.id
+		ORDER BY tags.name)`
+
+// setStoryTags makes tags the complete set of tags on a story, creating any
+// tags that do not exist yet. Tags must already be normalised.
+func setStoryTags(tx *sql.Tx, storyID int, tags []string) error {
+	_, err := tx.Exec(`
+		INSERT INTO tags (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING`, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM story_tags
+		WHERE story_id = $1 AND tag_id NOT IN (SELECT id FROM tags WHERE name = ANY($2))`, storyID, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO story_tags (story_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2)
+		ON CONFLICT DO NOTHING`, storyID, pq.Array(tags))
+	return err
+}
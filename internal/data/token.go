@@ -0,0 +1,70 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"time"
+)
+
+// Token scopes control what a personal API token may be used for.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// Token represents a personal API token. Only the SHA-256 hash is stored;
+// the plaintext is available once, right after the token is generated.
+type Token struct {
+	ID         int        `json:"id"`
+	Plaintext  string     `json:"token,omitempty"`
+	Hash       []byte     `json:"-"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ValidScope reports whether scope is one of the known token scopes.
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite
+}
+
+// generateToken creates a random token for the user. A zero ttl means the token never expires.
+func generateToken(userID int, name, scope string, ttl time.Duration) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Name:   name,
+		Scope:  scope,
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		token.ExpiresAt = &expiresAt
+	}
+
+	plaintext, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	token.Plaintext = plaintext
+	token.Hash = hashToken(token.Plaintext)
+
+	return token, nil
+}
+
+// randomToken returns 160 random bits encoded as an unpadded base-32 string.
+func randomToken() (string, error) {
+	randomBytes := make([]byte, 20)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// hashToken returns the SHA-256 hash used to look tokens up in the database.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TokenModel wraps a sql.DB connection pool for working with personal API tokens
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// New generates a token for the user and stores its hash in the database.
+func (m *TokenModel) New(userID int, name, scope string, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, name, scope, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(token)
+	return token, err
+}
+
+// Insert adds a token to the 'tokens' table and sets its ID and created_at fields
+func (m *TokenModel) Insert(token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, name, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	return m.DB.QueryRow(query, token.Hash, token.UserID, token.Name, token.Scope, token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+}
+
+// GetAllForUser lists the user's tokens, newest first
+func (m *TokenModel) GetAllForUser(userID int) ([]*Token, error) {
+	query := `
+		SELECT id, user_id, name, scope, expires_at, last_used_at, created_at
+		FROM tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*Token
+	for rows.Next() {
+		var token Token
+		err := rows.Scan(
+			&token.ID,
+			&token.UserID,
+			&token.Name,
+			&token.Scope,
+			&token.ExpiresAt,
+			&token.LastUsedAt,
+			&token.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetUserForToken looks up the owner of an unexpired token and records that the token was used
+func (m *TokenModel) GetUserForToken(plaintext string) (*User, *Token, error) {
+	query := `
+		SELECT ` + userColumns + `,
+			   tokens.id, tokens.name, tokens.scope, tokens.expires_at, tokens.created_at
+		FROM tokens
+		INNER JOIN users ON tokens.user_id = users.id
+		WHERE tokens.hash = $1
+		AND (tokens.expires_at IS NULL OR tokens.expires_at > NOW())`
+
+	var user User
+	var token Token
+	dest := append(userDest(&user),
+		&token.ID,
+		&token.Name,
+		&token.Scope,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	err := m.DB.QueryRow(query, hashToken(plaintext)).Scan(dest...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, ErrRecordNotFound
+		}
+		return nil, nil, err
+	}
+	token.UserID = user.ID
+
+	_, err = m.DB.Exec(`UPDATE tokens SET last_used_at = NOW() WHERE id = $1`, token.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &user, &token, nil
+}
+
+// Delete revokes a token by its ID if it belongs to the given user ID
+func (m *TokenModel) Delete(id int, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -2,32 +2,76 @@ package data
 
 import (
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // User represents a user in the system, including authentication details and timestamps
 type User struct {
-	ID           int
-	Email        string
-	PasswordHash string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID              int
+	Email           string
+	PasswordHash    string
+	Role            Role
+	EmailVerifiedAt *time.Time // nil until the user follows the verification link
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	// Two-factor authentication
+	TOTPSecret    []byte     // AES-GCM encrypted TOTP secret, nil when 2FA is off
+	TOTPEnabledAt *time.Time // nil when 2FA is off
+	TOTPLastStep  int64      // Last accepted TOTP time step, used to reject replayed codes
+	RecoveryCodes []string   // SHA-256 hashes of the unused recovery codes
+
+	// Login throttling
+	FailedLoginCount  int        // consecutive failed logins since the last success
+	LastFailedLoginAt *time.Time // time of the most recent failed login
+	LockedUntil       *time.Time // logins are refused until this time
+
+	// Admin controls
+	DisabledAt            *time.Time // set while an admin has disabled the account
+	PasswordResetRequired bool       // the user must choose a new password before logging in again
+}
+
+// TOTPEnabled reports whether the user has turned on two-factor authentication
+func (u *User) TOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// HasRole reports whether the user's role grants at least the privileges of role
+func (u *User) HasRole(role Role) bool {
+	return u.Role.AtLeast(role)
 }
 
-// SetPassword hashes a plaintext password using bcrypt and stores the result in the PasswordHash field
+// IsVerified reports whether the user has confirmed their email address
+func (u *User) IsVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// SetPassword hashes a plaintext password with the current password hasher and stores the result in the PasswordHash field
 func (u *User) SetPassword(plaintext string) error {
-	// bcrypt.GenerateFromPassword hashes the plaintext with a cost of 12 (relatively secure)
-	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), 12)
+	hash, err := Passwords.Hash(plaintext)
 	if err != nil {
 		return err // Return the error if hashing fails
 	}
-	u.PasswordHash = string(hash) // Store the resulting hash in the User struct
+	u.PasswordHash = hash // Store the resulting hash in the User struct
 	return nil
 }
 
-// MatchesPassword compares the stored hash with a plaintext password to verify authentication
+// MatchesPassword compares the stored hash with a plaintext password to verify authentication.
+// Hashes in any supported format are accepted; it returns nil if the password matches.
 func (u *User) MatchesPassword(plaintext string) error {
-	// bcrypt.CompareHashAndPassword returns nil if the password matches the hash
-	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(plaintext))
+	return Passwords.Verify(u.PasswordHash, plaintext)
+}
+
+// PasswordNeedsRehash reports whether the stored hash should be upgraded to the current hasher's format and parameters
+func (u *User) PasswordNeedsRehash() bool {
+	return Passwords.NeedsRehash(u.PasswordHash)
+}
+
+// IsDisabled reports whether an admin has disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
+}
+
+// IsLocked reports whether the account is temporarily locked at the given time
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
 }
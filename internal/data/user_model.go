@@ -3,6 +3,9 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // Custom errors for specific user-related database scenarios
@@ -16,15 +19,46 @@ type UserModel struct {
 	DB *sql.DB
 }
 
+// userColumns is the column list every query that loads a full User selects,
+// in the order expected by userDest
+const userColumns = `users.id, users.email, users.password_hash, users.role, users.email_verified_at,
+	users.created_at, users.updated_at,
+	users.totp_secret, users.totp_enabled_at, users.totp_last_step, users.recovery_codes,
+	users.failed_login_count, users.last_failed_login_at, users.locked_until,
+	users.disabled_at, users.password_reset_required`
+
+// userDest returns the scan destinations matching userColumns
+func userDest(user *User) []interface{} {
+	return []interface{}{
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.TOTPSecret,
+		&user.TOTPEnabledAt,
+		&user.TOTPLastStep,
+		pq.Array(&user.RecoveryCodes),
+		&user.FailedLoginCount,
+		&user.LastFailedLoginAt,
+		&user.LockedUntil,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
+	}
+}
+
 // Insert adds a new user to the database and sets the ID, created_at, and updated_at fields
 func (m *UserModel) Insert(user *User) error {
 	query := `
 		INSERT INTO users (email, password_hash)
 		VALUES ($1, $2)
-		RETURNING id, created_at, updated_at`
+		RETURNING id, role, created_at, updated_at`
 	// Execute the insert query and scan returned fields into the user struct
 	err := m.DB.QueryRow(query, user.Email, user.PasswordHash).Scan(
 		&user.ID,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -44,18 +78,12 @@ func (m *UserModel) Insert(user *User) error {
 func (m *UserModel) GetByEmail(email string) (*User, error) {
 	// Query the database and scan the row into a User struct
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE email = $1`
 
 	var user User
-	err := m.DB.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := m.DB.QueryRow(query, email).Scan(userDest(&user)...)
 	// If no rows returned, wrap and return ErrRecordNotFound
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -69,18 +97,12 @@ func (m *UserModel) GetByEmail(email string) (*User, error) {
 // GetByID fetches a user from the database by their ID
 func (m *UserModel) GetByID(id int) (*User, error) {
 	query := `
-		SELECT id, email, password_hash, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1`
 	// Execute the query and populate the user struct
 	var user User
-	err := m.DB.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := m.DB.QueryRow(query, id).Scan(userDest(&user)...)
 	// Handle case where user ID does not exist in the database
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -90,3 +112,250 @@ func (m *UserModel) GetByID(id int) (*User, error) {
 	}
 	return &user, nil
 }
+
+// UpdatePassword stores a new password hash for the user, clears any required reset and bumps updated_at
+func (m *UserModel) UpdatePassword(user *User) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, password_reset_required = FALSE, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at`
+
+	err := m.DB.QueryRow(query, user.PasswordHash, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	user.PasswordResetRequired = false
+	return nil
+}
+
+// MarkEmailVerified records that the user has confirmed their email address
+func (m *UserModel) MarkEmailVerified(user *User) error {
+	query := `
+		UPDATE users
+		SET email_verified_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING email_verified_at, updated_at`
+
+	err := m.DB.QueryRow(query, user.ID).Scan(&user.EmailVerifiedAt, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// EnableTOTP stores the encrypted TOTP secret and hashed recovery codes and switches 2FA on
+func (m *UserModel) EnableTOTP(user *User) error {
+	query := `
+		UPDATE users
+		SET totp_secret = $1, recovery_codes = $2, totp_enabled_at = NOW(), totp_last_step = 0, updated_at = NOW()
+		WHERE id = $3
+		RETURNING totp_enabled_at, updated_at`
+
+	return m.DB.QueryRow(query, user.TOTPSecret, pq.Array(user.RecoveryCodes), user.ID).Scan(
+		&user.TOTPEnabledAt,
+		&user.UpdatedAt,
+	)
+}
+
+// DisableTOTP removes the user's TOTP secret and recovery codes
+func (m *UserModel) DisableTOTP(user *User) error {
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, recovery_codes = '{}', totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err := m.DB.QueryRow(query, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	user.TOTPSecret = nil
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	return nil
+}
+
+// SetRecoveryCodes replaces the user's hashed recovery codes
+func (m *UserModel) SetRecoveryCodes(user *User) error {
+	query := `
+		UPDATE users
+		SET recovery_codes = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at`
+
+	return m.DB.QueryRow(query, pq.Array(user.RecoveryCodes), user.ID).Scan(&user.UpdatedAt)
+}
+
+// UseTOTPStep records that the code for the given time step was used. It reports
+// false if that step (or a later one) was already used, so codes cannot be replayed.
+func (m *UserModel) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := m.DB.Exec(`UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// UseRecoveryCode removes the hashed recovery code from the user's list. It reports
+// false if the code is not (or no longer) one of the user's codes.
+func (m *UserModel) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	query := `
+		UPDATE users
+		SET recovery_codes = array_remove(recovery_codes, $1), updated_at = NOW()
+		WHERE id = $2 AND $1 = ANY(recovery_codes)`
+
+	result, err := m.DB.Exec(query, codeHash, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// RecordLoginFailure increments the user's consecutive failed login count
+func (m *UserModel) RecordLoginFailure(user *User, at time.Time) error {
+	query := `
+		UPDATE users
+		SET failed_login_count = failed_login_count + 1, last_failed_login_at = $1
+		WHERE id = $2
+		RETURNING failed_login_count, last_failed_login_at`
+
+	return m.DB.QueryRow(query, at, user.ID).Scan(&user.FailedLoginCount, &user.LastFailedLoginAt)
+}
+
+// Lock refuses logins for the user until the given time
+func (m *UserModel) Lock(user *User, until time.Time) error {
+	_, err := m.DB.Exec(`UPDATE users SET locked_until = $1 WHERE id = $2`, until, user.ID)
+	if err != nil {
+		return err
+	}
+	user.LockedUntil = &until
+	return nil
+}
+
+// ResetLoginFailures clears the failed login count and any lock after a successful login
+func (m *UserModel) ResetLoginFailures(user *User) error {
+	if user.FailedLoginCount == 0 && user.LockedUntil == nil {
+		return nil
+	}
+
+	query := `
+		UPDATE users
+		SET failed_login_count = 0, last_failed_login_at = NULL, locked_until = NULL
+		WHERE id = $1`
+
+	_, err := m.DB.Exec(query, user.ID)
+	if err != nil {
+		return err
+	}
+	user.FailedLoginCount = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	return nil
+}
+
+// SetRole changes the user's role
+func (m *UserModel) SetRole(user *User, role Role) error {
+	query := `
+		UPDATE users
+		SET role = $1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING updated_at`
+
+	err := m.DB.QueryRow(query, role, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	user.Role = role
+	return nil
+}
+
+// SetDisabled disables or re-enables the user's account
+func (m *UserModel) SetDisabled(user *User, disabled bool) error {
+	query := `
+		UPDATE users
+		SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, NOW()) END, updated_at = NOW()
+		WHERE id = $2
+		RETURNING disabled_at, updated_at`
+
+	err := m.DB.QueryRow(query, disabled, user.ID).Scan(&user.DisabledAt, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RequirePasswordReset makes the user choose a new password before they can log in again
+func (m *UserModel) RequirePasswordReset(user *User) error {
+	query := `
+		UPDATE users
+		SET password_reset_required = TRUE, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err := m.DB.QueryRow(query, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		return err
+	}
+	user.PasswordResetRequired = true
+	return nil
+}
+
+// userSortColumns maps the sort keys accepted by List to columns
+var userSortColumns = map[string]string{
+	"email":      "users.email",
+	"role":       "users.role",
+	"created_at": "users.created_at",
+}
+
+// List returns one page of users whose email contains the search text, for the admin area
+func (m *UserModel) List(filters Filters) ([]*User, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), ` + userColumns + `
+		FROM users
+		WHERE ($1 = '' OR users.email ILIKE $2)
+		ORDER BY ` + filters.orderBy(userSortColumns, "users.created_at DESC") + `, users.id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, filters.Search, filters.searchPattern(), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	var users []*User
+	for rows.Next() {
+		var user User
+		dest := append([]interface{}{&totalRecords}, userDest(&user)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, Metadata{}, err
+		}
+		users = append(users, &user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return users, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
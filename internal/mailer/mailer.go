@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email messages. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends messages through an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string // e.g. "Meka-tell-yuh <no-reply@example.com>"
+}
+
+// Send delivers the message via SMTP, authenticating when a username is set.
+func (m *SMTPMailer) Send(msg Message) error {
+	from, err := mail.ParseAddress(m.Sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := m.Host + ":" + strconv.Itoa(m.Port)
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, format(m.Sender, msg))
+}
+
+// LogMailer writes messages to a logger instead of sending them. Useful for local development.
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+// Send logs the full message.
+func (m *LogMailer) Send(msg Message) error {
+	m.Logger.Printf("email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message as an .eml file into Dir. Useful for local development and tests.
+type FileMailer struct {
+	Dir    string
+	Sender string
+}
+
+// Send writes the message to a new file named after the time and recipient.
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), sanitizeFilename(msg.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.Sender, msg), 0o644)
+}
+
+// format renders the message as an RFC 5322 document with CRLF line endings.
+func format(sender string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", sender)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// sanitizeFilename keeps only characters that are safe in file names.
+func sanitizeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' || r == '@' {
+			return r
+		}
+		return '_'
+	}, s)
+}
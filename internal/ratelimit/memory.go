@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in process memory. It is safe for concurrent use
+// but is not shared between instances of the application.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(key string, rate Rate, cost int, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	tokens, result := take(b.tokens, b.updated, rate, cost, now)
+	b.tokens = tokens
+	b.updated = now
+	b.full = now.Add(result.ResetAfter)
+
+	return result, nil
+}
+
+// Reset implements Store.
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.buckets, key)
+	return nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute.
+// A missing bucket behaves exactly like a full one. Callers must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
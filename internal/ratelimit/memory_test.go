@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+)
+
+func TestMemoryStore(t *testing.T) {
+	rate := Rate{Burst: 3, Interval: time.Minute}
+
+	type op struct {
+		call    string // allow, peek, hit, spend (an unchecked hit), reset or wait
+		advance time.Duration
+		allowed bool
+		retry   time.Duration
+	}
+	tests := []struct {
+		name string
+		ops  []op
+	}{
+		{"burst then refused", []op{
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: false, retry: time.Minute},
+		}},
+		{"peek does not spend", []op{
+			{call: "peek", allowed: true},
+			{call: "peek", allowed: true},
+			{call: "peek", allowed: true},
+			{call: "peek", allowed: true},
+		}},
+		{"hits push the next attempt out", []op{
+			{call: "hit", allowed: true},
+			{call: "hit", allowed: true},
+			{call: "hit", allowed: true},
+			{call: "hit", allowed: false},
+			{call: "peek", allowed: false, retry: 2 * time.Minute},
+			{call: "hit", allowed: false},
+			{call: "peek", allowed: false, retry: 3 * time.Minute},
+		}},
+		{"debt is capped at one burst", []op{
+			{call: "spend"}, {call: "spend"}, {call: "spend"},
+			{call: "spend"}, {call: "spend"}, {call: "spend"},
+			{call: "spend"}, {call: "spend"}, {call: "spend"},
+			{call: "peek", allowed: false, retry: 4 * time.Minute},
+		}},
+		{"reset restores the burst", []op{
+			{call: "spend"}, {call: "spend"}, {call: "spend"}, {call: "spend"},
+			{call: "reset"},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+		}},
+		{"refused until a token refills", []op{
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+			{call: "allow", allowed: true},
+			{call: "wait", advance: 30 * time.Second},
+			{call: "allow", allowed: false, retry: 30 * time.Second},
+			{call: "wait", advance: 30 * time.Second},
+			{call: "allow", allowed: true},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			limiter := &Limiter{Store: NewMemoryStore(), Clock: c}
+
+			for i, op := range tt.ops {
+				var result Result
+				var err error
+				switch op.call {
+				case "allow":
+					result, err = limiter.Allow("key", rate)
+				case "peek":
+					result, err = limiter.Peek("key", rate)
+				case "hit":
+					result, err = limiter.Hit("key", rate)
+				case "spend":
+					_, err = limiter.Hit("key", rate)
+				case "reset":
+					err = limiter.Reset("key")
+				case "wait":
+					c.Advance(op.advance)
+				}
+				if err != nil {
+					t.Fatalf("op %d (%s): %v", i, op.call, err)
+				}
+				if op.call == "spend" || op.call == "reset" || op.call == "wait" {
+					continue
+				}
+				if result.Allowed != op.allowed {
+					t.Errorf("op %d (%s): Allowed = %t; want %t", i, op.call, result.Allowed, op.allowed)
+				}
+				if op.retry != 0 && result.RetryAfter != op.retry {
+					t.Errorf("op %d (%s): RetryAfter = %s; want %s", i, op.call, result.RetryAfter, op.retry)
+				}
+			}
+		})
+	}
+}
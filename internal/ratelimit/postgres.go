@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that every
+// instance of the application shares the same limits.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// Take implements Store. The bucket row is locked for the duration of the
+// update so concurrent requests cannot both spend the same token.
+func (s *PostgresStore) Take(key string, rate Rate, cost int, now time.Time) (Result, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return Result{}, err
+	}
+	defer tx.Rollback()
+
+	// Make sure the row exists, starting with a full bucket
+	_, err = tx.Exec(`
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`, key, rate.Burst, now)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var tokens float64
+	var updated time.Time
+	err = tx.QueryRow(`SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1 FOR UPDATE`, key).Scan(&tokens, &updated)
+	if err != nil {
+		return Result{}, err
+	}
+
+	tokens, result := take(tokens, updated, rate, cost, now)
+
+	_, err = tx.Exec(`
+		UPDATE rate_limit_buckets
+		SET tokens = $1, updated_at = $2, full_at = $3
+		WHERE key = $4`, tokens, now, now.Add(result.ResetAfter), key)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, tx.Commit()
+}
+
+// Reset implements Store.
+func (s *PostgresStore) Reset(key string) error {
+	_, err := s.DB.Exec(`DELETE FROM rate_limit_buckets WHERE key = $1`, key)
+	return err
+}
+
+// DeleteFull removes buckets that have refilled completely; a missing bucket
+// behaves exactly like a full one. Run it periodically to keep the table small.
+func (s *PostgresStore) DeleteFull() (int64, error) {
+	result, err := s.DB.Exec(`DELETE FROM rate_limit_buckets WHERE full_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
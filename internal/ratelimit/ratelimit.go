@@ -0,0 +1,133 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable
+// storage: an in-memory store for a single instance and a Postgres store
+// shared between instances.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+)
+
+// Rate describes a token bucket that holds up to Burst tokens and regains one
+// token every Interval.
+type Rate struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// PerMinute returns a Rate allowing n requests per minute with a burst of n.
+func PerMinute(n int) Rate {
+	return Rate{Burst: n, Interval: time.Minute / time.Duration(n)}
+}
+
+// ParseRate parses a rate written as "N/unit", where unit is s, m or h, into
+// a bucket allowing N requests per unit with a burst of N.
+func ParseRate(s string) (Rate, error) {
+	count, unit, ok := strings.Cut(strings.TrimSpace(s), "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n < 1 {
+		return Rate{}, fmt.Errorf("invalid rate %q: want N/s, N/m or N/h", s)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Rate{}, fmt.Errorf("invalid rate %q: unit must be s, m or h", s)
+	}
+
+	return Rate{Burst: n, Interval: period / time.Duration(n)}, nil
+}
+
+// Result is the state of a bucket after a Take.
+type Result struct {
+	Allowed    bool          // whether the requested tokens were available (and, if cost > 0, consumed)
+	Limit      int           // bucket capacity
+	Remaining  int           // whole tokens left
+	RetryAfter time.Duration // time until the next token is available when not allowed
+	ResetAfter time.Duration // time until the bucket is full again
+}
+
+// Store keeps bucket state. Take removes cost tokens from the bucket for key
+// if enough are available; a cost of 0 only inspects the bucket.
+type Store interface {
+	Take(key string, rate Rate, cost int, now time.Time) (Result, error)
+	Reset(key string) error
+}
+
+// Limiter applies rates to keys using a Store and a Clock.
+type Limiter struct {
+	Store Store
+	Clock clock.Clock
+}
+
+// New returns a Limiter backed by store using the system clock.
+func New(store Store) *Limiter {
+	return &Limiter{Store: store, Clock: clock.System{}}
+}
+
+// Allow consumes one token for key.
+func (l *Limiter) Allow(key string, rate Rate) (Result, error) {
+	return l.Store.Take(key, rate, 1, l.Clock.Now())
+}
+
+// Peek reports whether a token is available for key without consuming it.
+func (l *Limiter) Peek(key string, rate Rate) (Result, error) {
+	return l.Store.Take(key, rate, 0, l.Clock.Now())
+}
+
+// Hit consumes one token for key even if the bucket is already empty, so
+// continued abuse keeps pushing the next allowed attempt further out.
+func (l *Limiter) Hit(key string, rate Rate) (Result, error) {
+	return l.Store.Take(key, rate, -1, l.Clock.Now())
+}
+
+// Reset forgets the bucket for key, restoring its full burst.
+func (l *Limiter) Reset(key string) error {
+	return l.Store.Reset(key)
+}
+
+// take is the token-bucket arithmetic shared by the stores. Given the stored
+// token count and the time it was last updated it returns the new token count
+// and the result. A negative cost consumes one token unconditionally, allowing
+// the balance to go negative.
+func take(tokens float64, updated time.Time, rate Rate, cost int, now time.Time) (float64, Result) {
+	perToken := float64(rate.Interval)
+
+	// Refill for the time elapsed since the last update
+	if elapsed := now.Sub(updated); elapsed > 0 {
+		tokens = math.Min(float64(rate.Burst), tokens+float64(elapsed)/perToken)
+	}
+
+	result := Result{Limit: rate.Burst}
+	switch {
+	case cost < 0:
+		// Cap the debt at one full burst so a shared address is never locked out indefinitely
+		tokens = math.Max(tokens-1, -float64(rate.Burst))
+		result.Allowed = tokens >= 0
+	case cost == 0:
+		result.Allowed = tokens >= 1
+	case tokens >= float64(cost):
+		tokens -= float64(cost)
+		result.Allowed = true
+	}
+
+	if !result.Allowed {
+		needed := math.Max(float64(cost), 1) - tokens
+		result.RetryAfter = time.Duration(needed * perToken)
+	}
+	result.Remaining = int(math.Max(0, math.Floor(tokens)))
+	result.ResetAfter = time.Duration((float64(rate.Burst) - tokens) * perToken)
+
+	return tokens, result
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{"10/s", Rate{Burst: 10, Interval: 100 * time.Millisecond}, false},
+		{"60/m", Rate{Burst: 60, Interval: time.Second}, false},
+		{" 4/h ", Rate{Burst: 4, Interval: 15 * time.Minute}, false},
+		{"1/m", Rate{Burst: 1, Interval: time.Minute}, false},
+		{"", Rate{}, true},
+		{"10", Rate{}, true},
+		{"0/m", Rate{}, true},
+		{"-5/m", Rate{}, true},
+		{"ten/m", Rate{}, true},
+		{"10/d", Rate{}, true},
+		{"10/", Rate{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v; want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %+v; want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRefill(t *testing.T) {
+	rate := PerMinute(6) // a token every 10 seconds
+
+	tests := []struct {
+		name      string
+		spend     int
+		advance   time.Duration
+		remaining int // after peeking
+		reset     time.Duration
+	}{
+		{"full", 0, 0, 6, 0},
+		{"emptied", 6, 0, 0, time.Minute},
+		{"part of a token back", 6, 5 * time.Second, 0, 55 * time.Second},
+		{"one token back", 6, 10 * time.Second, 1, 50 * time.Second},
+		{"several tokens back", 6, 35 * time.Second, 3, 25 * time.Second},
+		{"refilled", 6, time.Minute, 6, 0},
+		{"never above burst", 1, time.Hour, 6, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+			limiter := &Limiter{Store: NewMemoryStore(), Clock: c}
+
+			for i := 0; i < tt.spend; i++ {
+				if result, err := limiter.Allow("key", rate); err != nil || !result.Allowed {
+					t.Fatalf("Allow %d = %+v, %v; want allowed", i, result, err)
+				}
+			}
+			c.Advance(tt.advance)
+
+			result, err := limiter.Peek("key", rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Limit != 6 {
+				t.Errorf("Limit = %d; want 6", result.Limit)
+			}
+			if result.Remaining != tt.remaining {
+				t.Errorf("Remaining = %d; want %d", result.Remaining, tt.remaining)
+			}
+			if result.ResetAfter != tt.reset {
+				t.Errorf("ResetAfter = %s; want %s", result.ResetAfter, tt.reset)
+			}
+		})
+	}
+}
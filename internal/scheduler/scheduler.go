@@ -0,0 +1,70 @@
+// Package scheduler publishes stories whose scheduled publication time has come.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// Store publishes the scheduled stories due by now. *data.StoryModel implements it.
+type Store interface {
+	PublishDue(now time.Time) ([]*data.Story, error)
+}
+
+// Scheduler checks for due stories every Interval. Times are read from Clock,
+// so tests can drive it with a clock.Manual and call Tick directly.
+type Scheduler struct {
+	Store     Store
+	Clock     clock.Clock
+	Interval  time.Duration
+	ErrorLog  *log.Logger
+	OnPublish func(story *data.Story) // called once for every story published; may be nil
+}
+
+// New returns a scheduler using the system clock that checks once a minute.
+func New(store Store, errorLog *log.Logger, onPublish func(*data.Story)) *Scheduler {
+	return &Scheduler{
+		Store:     store,
+		Clock:     clock.System{},
+		Interval:  time.Minute,
+		ErrorLog:  errorLog,
+		OnPublish: onPublish,
+	}
+}
+
+// Run publishes due stories straight away and then every Interval until ctx
+// is cancelled. Errors are logged and retried on the next tick.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Tick(); err != nil {
+			s.ErrorLog.Printf("publishing scheduled stories: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick publishes every story due by the clock's current time, calls OnPublish
+// for each and returns them.
+func (s *Scheduler) Tick() ([]*data.Story, error) {
+	stories, err := s.Store.PublishDue(s.Clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if s.OnPublish != nil {
+		for _, story := range stories {
+			s.OnPublish(story)
+		}
+	}
+	return stories, nil
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RudyItza/ahsehdis/internal/clock"
+	"github.com/RudyItza/ahsehdis/internal/data"
+)
+
+// memoryStore publishes stories the way *data.StoryModel does, without a database.
+type memoryStore struct {
+	stories []*data.Story
+	err     error
+}
+
+func (s *memoryStore) PublishDue(now time.Time) ([]*data.Story, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var due []*data.Story
+	for _, story := range s.stories {
+		if story.Status == data.StatusScheduled && !story.ScheduledAt.After(now) {
+			story.Status = data.StatusPublished
+			story.PublishedAt = story.ScheduledAt
+			story.ScheduledAt = nil
+			due = append(due, story)
+		}
+	}
+	return due, nil
+}
+
+func scheduled(id int, at time.Time) *data.Story {
+	return &data.Story{ID: id, Status: data.StatusScheduled, ScheduledAt: &at}
+}
+
+func TestTick(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	store := &memoryStore{stories: []*data.Story{
+		scheduled(1, start.Add(-time.Minute)),
+		scheduled(2, start),
+		scheduled(3, start.Add(time.Hour)),
+		scheduled(4, start.Add(2*time.Hour)),
+		{ID: 5, Status: data.StatusDraft},
+	}}
+	c := clock.NewManual(start)
+
+	published := make(map[int]int)
+	s := New(store, nil, func(story *data.Story) {
+		published[story.ID]++
+	})
+	s.Clock = c
+
+	tests := []struct {
+		advance time.Duration
+		want    []int // IDs published by this tick
+	}{
+		{0, []int{1, 2}},
+		{time.Minute, nil},
+		{time.Hour, []int{3}},
+		{2 * time.Hour, []int{4}},
+		{24 * time.Hour, nil},
+	}
+
+	for _, tt := range tests {
+		c.Advance(tt.advance)
+		stories, err := s.Tick()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []int
+		for _, story := range stories {
+			got = append(got, story.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Tick at %s published %v; want %v", c.Now().Format(time.Kitchen), got, tt.want)
+		}
+	}
+
+	for id := 1; id <= 4; id++ {
+		if published[id] != 1 {
+			t.Errorf("OnPublish called %d times for story %d; want 1", published[id], id)
+		}
+	}
+	if published[5] != 0 {
+		t.Errorf("OnPublish called for draft story 5")
+	}
+}
+
+func TestTickError(t *testing.T) {
+	store := &memoryStore{err: errors.New("connection refused")}
+	s := New(store, nil, func(story *data.Story) {
+		t.Errorf("OnPublish called for story %d after an error", story.ID)
+	})
+	s.Clock = clock.NewManual(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	if _, err := s.Tick(); err == nil {
+		t.Error("Tick returned no error; want the store's error")
+	}
+}
@@ -0,0 +1,76 @@
+// Package slug turns story titles into readable URL path segments.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxLength caps a slug's length in bytes; longer slugs are cut at a word boundary.
+const MaxLength = 80
+
+// Fallback is the slug for titles with no letters or digits that can be written in ASCII.
+const Fallback = "story"
+
+// transliterations spells letters outside ASCII with ASCII letters. Letters
+// missing from the table separate words like punctuation does.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Make returns the slug for a title: lower-case ASCII letters and digits with
+// every run of anything else made a single hyphen, so "Bredda Anansi & Tiger!"
+// becomes "bredda-anansi-and-tiger" and "Café Olé" becomes "cafe-ole".
+// Apostrophes are dropped so "Anansi's" stays one word.
+func Make(title string) string {
+	var b strings.Builder
+	gap := false
+	write := func(s string) {
+		if gap && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		gap = false
+		b.WriteString(s)
+	}
+
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '\'' || r == '’':
+			continue
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(r))
+		case r == '&':
+			// A word of its own, so "Tiger&Anansi" reads "tiger-and-anansi"
+			gap = true
+			write("and")
+			gap = true
+		case transliterations[r] != "":
+			write(transliterations[r])
+		default:
+			gap = true
+		}
+	}
+
+	s := b.String()
+	if len(s) > MaxLength {
+		s = s[:MaxLength]
+		if i := strings.LastIndexByte(s, '-'); i > 0 {
+			s = s[:i]
+		}
+	}
+	if s == "" {
+		return Fallback
+	}
+	return s
+}
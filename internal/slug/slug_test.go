@@ -0,0 +1,52 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Bredda Anansi & Tiger!", "bredda-anansi-and-tiger"},
+		{"Tiger&Anansi", "tiger-and-anansi"},
+		{"Café Olé", "cafe-ole"},
+		{"Anansi's Pot of Wisdom", "anansis-pot-of-wisdom"},
+		{"Anansi’s Web", "anansis-web"},
+		{"  --Spaces   and---dashes--  ", "spaces-and-dashes"},
+		{"Story 42", "story-42"},
+		{"Straße", "strasse"},
+		{"", Fallback},
+		{"   ", Fallback},
+		{"!!!", Fallback},
+		{"故事", Fallback},
+		{"Ωμέγα", Fallback},
+		{"故事 Tiger", "tiger"},
+		{"Tiger 故事 Anansi", "tiger-anansi"},
+	}
+
+	for _, tt := range tests {
+		if got := Make(tt.title); got != tt.want {
+			t.Errorf("Make(%q) = %q; want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestMakeLong(t *testing.T) {
+	title := strings.Repeat("anansi ", 20) // 139 characters of slug
+	got := Make(title)
+
+	if len(got) > MaxLength {
+		t.Errorf("Make of a long title = %q, %d bytes; want at most %d", got, len(got), MaxLength)
+	}
+	if strings.HasSuffix(got, "-") || !strings.HasSuffix(got, "anansi") {
+		t.Errorf("Make of a long title = %q; want it cut at a word boundary", got)
+	}
+
+	// A single word longer than MaxLength has no boundary to cut at
+	if got := Make(strings.Repeat("a", 100)); got != strings.Repeat("a", MaxLength) {
+		t.Errorf("Make of a long word = %q; want it cut at %d bytes", got, MaxLength)
+	}
+}
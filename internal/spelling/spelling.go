@@ -0,0 +1,115 @@
+// Package spelling knows the different ways contributors spell the same word,
+// so a search for one spelling can find stories written with another.
+package spelling
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed variants.txt
+var defaultVariants string
+
+// Dictionary maps each known spelling to the other spellings of the same word.
+type Dictionary struct {
+	variants map[string][]string
+}
+
+// Default returns the dictionary of Kriol spellings built into the application.
+func Default() *Dictionary {
+	d, err := Parse(strings.NewReader(defaultVariants))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// Load reads a dictionary from a file in the format described by Parse.
+func Load(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Parse reads a dictionary with one group of spellings per line, separated by
+// commas. Blank lines and lines starting with # are ignored. A spelling must
+// be a single word of letters and digits, and may only belong to one group.
+func Parse(r io.Reader) (*Dictionary, error) {
+	d := &Dictionary{variants: map[string][]string{}}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var group []string
+		for _, field := range strings.Split(text, ",") {
+			word := normalize(field)
+			if word == "" {
+				continue
+			}
+			if strings.IndexFunc(word, notWordRune) >= 0 {
+				return nil, fmt.Errorf("line %d: %q is not a single word of letters and digits", line, word)
+			}
+			if _, seen := d.variants[word]; seen {
+				return nil, fmt.Errorf("line %d: %q is already in another group", line, word)
+			}
+			d.variants[word] = nil
+			group = append(group, word)
+		}
+		if len(group) < 2 {
+			return nil, fmt.Errorf("line %d: a group needs at least two spellings", line)
+		}
+
+		for _, word := range group {
+			for _, other := range group {
+				if other != word {
+					d.variants[word] = append(d.variants[word], other)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Variants returns the other spellings of word, or nil if it has none.
+func (d *Dictionary) Variants(word string) []string {
+	if d == nil {
+		return nil
+	}
+	return d.variants[normalize(word)]
+}
+
+// Len returns the number of spellings the dictionary knows.
+func (d *Dictionary) Len() int {
+	return len(d.variants)
+}
+
+// notWordRune reports whether r cannot be part of a spelling. Searches are
+// split into words the same way, so only spellings like these can match one.
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// normalize lower-cases a word and drops apostrophes and surrounding space.
+func normalize(word string) string {
+	return strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(strings.TrimSpace(word)))
+}
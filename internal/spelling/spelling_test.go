@@ -0,0 +1,59 @@
+package spelling
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	if Default().Len() == 0 {
+		t.Error("Default dictionary is empty")
+	}
+}
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader("# Kriol spellings\n\npikni, pickney, pikney\n Dem , Deh'm \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"pikni", []string{"pickney", "pikney"}},
+		{"PICKNEY", []string{"pikni", "pikney"}},
+		{"dem", []string{"dehm"}},
+		{"deh’m", []string{"dem"}},
+		{"tiger", nil},
+	}
+	for _, tt := range tests {
+		if got := d.Variants(tt.word); !slices.Equal(got, tt.want) {
+			t.Errorf("Variants(%q) = %q; want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // in the error
+	}{
+		{"single spelling", "pikni\n", "line 1: a group needs at least two spellings"},
+		{"repeated spelling", "pikni, pickney\npikni, pikney\n", `line 2: "pikni" is already in another group`},
+		{"two words", "# header\npikni, pick ney\n", `line 2: "pick ney" is not a single word`},
+		{"punctuation", "a&b, ab\n", `line 1: "a&b" is not a single word`},
+		{"tsquery syntax", "pikni, pickney:*\n", `line 1: "pickney:*" is not a single word`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v; want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
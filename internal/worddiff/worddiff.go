@@ -0,0 +1,143 @@
+// Package worddiff compares two texts word by word, for showing what changed
+// between revisions of a story.
+package worddiff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind says whether a piece of text is shared, added or removed.
+type Kind string
+
+// Kinds of Op.
+const (
+	Equal  Kind = "equal"
+	Insert Kind = "insert"
+	Delete Kind = "delete"
+)
+
+// maxCells caps the size of the table lcs builds, in entries. Changes too big
+// to compare word by word within it are shown as replacing the whole changed
+// part, which keeps memory bounded however long the texts are.
+const maxCells = 4 << 20
+
+// Op is a run of text that is in both texts, only in the new one (Insert) or
+// only in the old one (Delete).
+type Op struct {
+	Kind Kind
+	Text string
+}
+
+// Diff returns the edits that turn a into b. Words and the whitespace between
+// them are compared as units, so a changed word is shown as a whole deletion
+// followed by a whole insertion. Reading the Equal and Delete ops gives back a;
+// reading the Equal and Insert ops gives back b.
+func Diff(a, b string) []Op {
+	x, y := tokenize(a), tokenize(b)
+
+	// Trim the common prefix and suffix so the table only covers the changed middle
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	ops = appendOp(ops, Equal, x[:prefix]...)
+	ops = append(ops, lcs(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	ops = appendOp(ops, Equal, x[len(x)-suffix:]...)
+	return ops
+}
+
+// Changed reports whether ops contain any insertions or deletions.
+func Changed(ops []Op) bool {
+	for _, op := range ops {
+		if op.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// lcs diffs two token lists using a longest common subsequence table. Lists
+// too long for a table of maxCells entries are diffed as one deletion and one insertion.
+func lcs(x, y []string) []Op {
+	if (len(x)+1)*(len(y)+1) > maxCells {
+		return appendOp(appendOp(nil, Delete, x...), Insert, y...)
+	}
+
+	// lengths[i][j] is the LCS length of x[i:] and y[j:]
+	lengths := make([][]int, len(x)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var ops []Op
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			ops = appendOp(ops, Equal, x[i])
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			ops = appendOp(ops, Delete, x[i])
+			i++
+		default:
+			ops = appendOp(ops, Insert, y[j])
+			j++
+		}
+	}
+	ops = appendOp(ops, Delete, x[i:]...)
+	ops = appendOp(ops, Insert, y[j:]...)
+	return ops
+}
+
+// appendOp adds tokens to ops, merging them into the last op when it has the same kind.
+func appendOp(ops []Op, kind Kind, tokens ...string) []Op {
+	if len(tokens) == 0 {
+		return ops
+	}
+	text := strings.Join(tokens, "")
+	if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+		ops[n-1].Text += text
+		return ops
+	}
+	return append(ops, Op{Kind: kind, Text: text})
+}
+
+// tokenize splits s into alternating runs of whitespace and non-whitespace.
+func tokenize(s string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(s, start) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// isSpaceAt reports whether the rune starting at byte i of s is whitespace.
+func isSpaceAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,94 @@
+package worddiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Op
+	}{
+		{"both empty", "", "", nil},
+		{"unchanged", "Bredda Anansi", "Bredda Anansi", []Op{{Equal, "Bredda Anansi"}}},
+		{"from empty", "", "new story", []Op{{Insert, "new story"}}},
+		{"to empty", "old story", "", []Op{{Delete, "old story"}}},
+		{"word replaced", "the quick fox", "the slow fox",
+			[]Op{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}}},
+		{"word added", "the fox", "the quick fox",
+			[]Op{{Equal, "the "}, {Insert, "quick "}, {Equal, "fox"}}},
+		{"word removed", "the quick fox", "the fox",
+			[]Op{{Equal, "the "}, {Delete, "quick "}, {Equal, "fox"}}},
+		{"whitespace changed", "one two", "one  two",
+			[]Op{{Equal, "one"}, {Delete, " "}, {Insert, "  "}, {Equal, "two"}}},
+		{"non-ASCII words", "mi nuh kno", "mi nuh nuo",
+			[]Op{{Equal, "mi nuh "}, {Delete, "kno"}, {Insert, "nuo"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff(%q, %q) = %v; want %v", tt.a, tt.b, got, tt.want)
+			}
+			checkRebuilds(t, got, tt.a, tt.b)
+			if Changed(got) != (tt.a != tt.b) {
+				t.Errorf("Changed = %t; want %t", Changed(got), tt.a != tt.b)
+			}
+		})
+	}
+}
+
+func TestDiffRebuilds(t *testing.T) {
+	pairs := [][2]string{
+		{"a b c d e f", "a c e g"},
+		{"Once upon a time\nthere was a spider.", "Once upon a time,\nthere were two spiders!"},
+		{"  leading and trailing  ", "leading\tand trailing"},
+	}
+	for _, pair := range pairs {
+		checkRebuilds(t, Diff(pair[0], pair[1]), pair[0], pair[1])
+	}
+}
+
+// checkRebuilds fails the test unless ops turn back into a and b
+func checkRebuilds(t *testing.T, ops []Op, a, b string) {
+	t.Helper()
+	var before, after strings.Builder
+	for _, op := range ops {
+		if op.Kind != Insert {
+			before.WriteString(op.Text)
+		}
+		if op.Kind != Delete {
+			after.WriteString(op.Text)
+		}
+	}
+	if before.String() != a || after.String() != b {
+		t.Errorf("ops %v rebuild %q and %q; want %q and %q", ops, before.String(), after.String(), a, b)
+	}
+}
+
+func TestDiffLargeChange(t *testing.T) {
+	// Too many changed words for the table: the changed middle is replaced whole
+	var x, y []string
+	for i := 0; i < 3000; i++ {
+		x = append(x, "a")
+		y = append(y, "b")
+	}
+	a := "start " + strings.Join(x, " ") + " end"
+	b := "start " + strings.Join(y, " ") + " end"
+
+	got := Diff(a, b)
+	want := []Op{
+		{Equal, "start "},
+		{Delete, strings.Join(x, " ")},
+		{Insert, strings.Join(y, " ")},
+		{Equal, " end"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff of a large change returned %d ops; want a whole deletion and insertion", len(got))
+	}
+	checkRebuilds(t, got, a, b)
+}
@@ -0,0 +1,12 @@
+CREATE TABLE tokens (
+    id SERIAL PRIMARY KEY,
+    hash BYTEA UNIQUE NOT NULL,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'write')),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX tokens_user_id_idx ON tokens(user_id);
@@ -0,0 +1,14 @@
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    token_hash BYTEA UNIQUE NOT NULL,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    data TEXT NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions(user_id);
+CREATE INDEX sessions_expires_at_idx ON sessions(expires_at);
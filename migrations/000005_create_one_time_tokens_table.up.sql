@@ -0,0 +1,10 @@
+CREATE TABLE one_time_tokens (
+    hash BYTEA PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX one_time_tokens_user_id_purpose_idx ON one_time_tokens(user_id, purpose);
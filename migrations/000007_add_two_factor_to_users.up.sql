@@ -0,0 +1,7 @@
+-- totp_secret is AES-GCM encrypted by the application; recovery_codes holds
+-- SHA-256 hashes of the unused codes.
+ALTER TABLE users
+    ADD COLUMN totp_secret BYTEA,
+    ADD COLUMN totp_enabled_at TIMESTAMPTZ,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN recovery_codes TEXT[] NOT NULL DEFAULT '{}';
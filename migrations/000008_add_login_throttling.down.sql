@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS failed_login_count,
+    DROP COLUMN IF EXISTS last_failed_login_at,
+    DROP COLUMN IF EXISTS locked_until;
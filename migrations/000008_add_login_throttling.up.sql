@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN failed_login_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_failed_login_at TIMESTAMPTZ,
+    ADD COLUMN locked_until TIMESTAMPTZ;
+
+-- Shared token buckets used when rate limiting is configured to use Postgres
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX rate_limit_buckets_full_at_idx ON rate_limit_buckets(full_at);
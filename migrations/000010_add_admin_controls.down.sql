@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS stories_deleted_at_idx;
+ALTER TABLE stories DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,7 @@
+-- Accounts can be disabled by admins, or made to choose a new password before logging in again
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Stories can be soft-deleted and later restored
+ALTER TABLE stories ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX stories_deleted_at_idx ON stories (deleted_at) WHERE deleted_at IS NOT NULL;
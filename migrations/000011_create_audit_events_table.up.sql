@@ -0,0 +1,36 @@
+-- actor_id deliberately has no foreign key: events must outlive the accounts they mention,
+-- and ON DELETE SET NULL would be an UPDATE, which the trigger below forbids.
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    action TEXT NOT NULL,
+    actor_id INT,
+    actor_email TEXT NOT NULL DEFAULT '',
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    metadata JSONB
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events(created_at);
+CREATE INDEX audit_events_action_idx ON audit_events(action);
+CREATE INDEX audit_events_actor_id_idx ON audit_events(actor_id);
+CREATE INDEX audit_events_target_idx ON audit_events(target_type, target_id);
+
+-- The log is append-only: rows can be inserted but never changed or removed
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_or_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
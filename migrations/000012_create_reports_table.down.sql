@@ -0,0 +1,3 @@
+ALTER TABLE stories DROP COLUMN IF EXISTS hidden_at;
+
+DROP TABLE IF EXISTS reports;
@@ -0,0 +1,19 @@
+-- Readers flag stories for moderators. A report stays open until a moderator resolves it.
+CREATE TABLE reports (
+    id BIGSERIAL PRIMARY KEY,
+    story_id INT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    reporter_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ,
+    resolved_by INT REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT CHECK (resolution IN ('dismissed', 'story_hidden', 'author_suspended'))
+);
+
+-- Each reader can have only one open report per story
+CREATE UNIQUE INDEX reports_open_story_reporter_idx ON reports (story_id, reporter_id) WHERE resolved_at IS NULL;
+CREATE INDEX reports_open_idx ON reports (story_id) WHERE resolved_at IS NULL;
+
+-- Hidden stories are kept out of public listings until a moderator reviews them
+ALTER TABLE stories ADD COLUMN hidden_at TIMESTAMPTZ;
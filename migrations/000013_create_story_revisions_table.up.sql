@@ -0,0 +1,16 @@
+-- Every version of a story, including the current one. Revisions are numbered from 1 per story.
+CREATE TABLE story_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    story_id INT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    editor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    restored_from INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (story_id, revision)
+);
+
+-- Existing stories start their history at their current text
+INSERT INTO story_revisions (story_id, revision, title, content, editor_id, created_at)
+SELECT id, 1, title, content, user_id, updated_at FROM stories;
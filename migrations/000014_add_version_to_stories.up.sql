@@ -0,0 +1,2 @@
+-- Incremented on every edit so concurrent edits can be detected
+ALTER TABLE stories ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS stories_user_id_status_idx;
+DROP INDEX IF EXISTS stories_published_idx;
+ALTER TABLE stories DROP COLUMN IF EXISTS published_at;
+ALTER TABLE stories DROP COLUMN IF EXISTS status;
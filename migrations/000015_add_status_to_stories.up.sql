@@ -0,0 +1,11 @@
+-- Who can see a story: only its author (draft, private), anyone with the link (unlisted) or everyone (published)
+ALTER TABLE stories ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'private', 'unlisted', 'published'));
+
+-- Set the first time a story is shared (unlisted or published); existing stories were published when written
+ALTER TABLE stories ADD COLUMN published_at TIMESTAMPTZ;
+UPDATE stories SET published_at = created_at;
+
+CREATE INDEX stories_published_idx ON stories (published_at DESC)
+    WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL;
+CREATE INDEX stories_user_id_status_idx ON stories (user_id, status);
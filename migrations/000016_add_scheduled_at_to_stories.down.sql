@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS stories_scheduled_at_idx;
+ALTER TABLE stories DROP CONSTRAINT IF EXISTS stories_scheduled_at_check;
+UPDATE stories SET status = 'draft' WHERE status = 'scheduled';
+ALTER TABLE stories DROP COLUMN IF EXISTS scheduled_at;
+ALTER TABLE stories DROP CONSTRAINT IF EXISTS stories_status_check;
+ALTER TABLE stories ADD CONSTRAINT stories_status_check
+    CHECK (status IN ('draft', 'private', 'unlisted', 'published'));
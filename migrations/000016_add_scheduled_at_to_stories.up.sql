@@ -0,0 +1,11 @@
+-- Scheduled stories stay out of sight until scheduled_at, when they are published
+ALTER TABLE stories DROP CONSTRAINT stories_status_check;
+ALTER TABLE stories ADD CONSTRAINT stories_status_check
+    CHECK (status IN ('draft', 'scheduled', 'private', 'unlisted', 'published'));
+
+ALTER TABLE stories ADD COLUMN scheduled_at TIMESTAMPTZ;
+ALTER TABLE stories ADD CONSTRAINT stories_scheduled_at_check
+    CHECK ((status = 'scheduled') = (scheduled_at IS NOT NULL));
+
+CREATE INDEX stories_scheduled_at_idx ON stories (scheduled_at)
+    WHERE status = 'scheduled' AND deleted_at IS NULL;
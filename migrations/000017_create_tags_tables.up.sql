@@ -0,0 +1,14 @@
+-- Tags group stories by topic. Names are stored normalised: lower case words joined by hyphens.
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE story_tags (
+    story_id INT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (story_id, tag_id)
+);
+
+CREATE INDEX story_tags_tag_id_idx ON story_tags (tag_id);
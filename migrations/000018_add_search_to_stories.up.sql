@@ -0,0 +1,7 @@
+-- Full-text search over stories. Postgres keeps the vector up to date on every insert and update;
+-- title words weigh more than content words when ranking.
+ALTER TABLE stories ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+) STORED;
+
+CREATE INDEX stories_search_vector_idx ON stories USING GIN (search_vector);
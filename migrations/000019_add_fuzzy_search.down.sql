@@ -0,0 +1,3 @@
+DROP MATERIALIZED VIEW IF EXISTS story_words;
+DROP INDEX IF EXISTS stories_content_trgm_idx;
+DROP INDEX IF EXISTS stories_title_trgm_idx;
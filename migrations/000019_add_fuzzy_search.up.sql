@@ -0,0 +1,18 @@
+-- Trigram indexes let searches match misspelled and variant spellings of words
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX stories_title_trgm_idx ON stories USING GIN (title gin_trgm_ops);
+CREATE INDEX stories_content_trgm_idx ON stories USING GIN (content gin_trgm_ops);
+
+-- Every word used in published stories, for "did you mean" suggestions. The
+-- application refreshes it periodically.
+CREATE MATERIALIZED VIEW story_words AS
+SELECT word, ndoc
+FROM ts_stat($$
+    SELECT to_tsvector('simple', title || ' ' || content)
+    FROM stories
+    WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL
+$$);
+
+CREATE UNIQUE INDEX story_words_word_idx ON story_words (word);
+CREATE INDEX story_words_word_trgm_idx ON story_words USING GIN (word gin_trgm_ops);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS stories_published_idx;
+CREATE INDEX stories_published_idx ON stories (published_at DESC)
+    WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL;
@@ -0,0 +1,6 @@
+-- Public listings page through stories by (published_at, id) instead of OFFSET,
+-- so the index covers the id tie-breaker too. The key is published_at rather than
+-- created_at so drafts and scheduled stories are listed from when they went out.
+DROP INDEX IF EXISTS stories_published_idx;
+CREATE INDEX stories_published_idx ON stories (published_at DESC, id DESC)
+    WHERE status = 'published' AND deleted_at IS NULL AND hidden_at IS NULL;
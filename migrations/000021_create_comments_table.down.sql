@@ -0,0 +1,3 @@
+ALTER TABLE stories DROP COLUMN IF EXISTS comments_locked_at;
+
+DROP TABLE IF EXISTS comments;
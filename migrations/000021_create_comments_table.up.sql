@@ -0,0 +1,18 @@
+-- Readers' comments on stories. A reply points at the comment it answers. Deleting a
+-- comment blanks it rather than removing the row, so the replies under it stay threaded.
+CREATE TABLE comments (
+    id BIGSERIAL PRIMARY KEY,
+    story_id INT NOT NULL REFERENCES stories(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX comments_story_id_idx ON comments (story_id, created_at);
+CREATE INDEX comments_parent_id_idx ON comments (parent_id);
+
+-- Authors can stop new comments on their story; existing comments stay visible
+ALTER TABLE stories ADD COLUMN comments_locked_at TIMESTAMPTZ;